@@ -0,0 +1,386 @@
+// Package breach tells whether a password is in a local copy of a breached
+// password corpus such as Have I Been Pwned's, so that passwords can be
+// checked without sending anything about them to a third party.
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prefixLength is the length in hex digits of the SHA-1 prefixes that HIBP
+// range files are named after.
+const prefixLength = 5
+
+const hexDigits = "0123456789abcdefABCDEF"
+
+// indexMagic starts every index file, and names its format.
+var indexMagic = []byte("HIBPIDX1")
+
+// keySize is the size of one key in an index file.
+const keySize = 8
+
+// minLineLength is the shortest line of a whole hash file: the hash, a
+// colon, a count and a newline. It bounds how many keys a file can hold.
+const minLineLength = 2*sha1.Size + 3
+
+var (
+	errUnsorted    = errors.New("hashes out of order")
+	errNotWritable = errors.New("cannot write breached password index")
+)
+
+// Corpus is a set of breached passwords. Only the first 64 bits of each
+// SHA-1 hash are kept: eight bytes a password, and a chance of a false
+// match that is negligible next to the size of any corpus. The keys live
+// sorted in an index file and are binary searched there, so a corpus of
+// hundreds of millions of passwords costs next to no memory.
+type Corpus struct {
+	index *os.File
+	n     int
+}
+
+// Load opens a corpus in HIBP range format. path is either a directory of
+// range files, each named after a five digit hash prefix (with or without
+// .txt) and holding SUFFIX:COUNT lines, or a single file of HASH:COUNT
+// lines with whole hashes. Entries with a count of zero are padding and
+// are skipped.
+//
+// The hashes are indexed into indexPath, the first time and again whenever
+// the corpus is newer than its index. Without an indexPath the index goes
+// next to the corpus, with .idx added, or into the user's cache or the
+// temporary directory when the corpus is somewhere read-only.
+func Load(path, indexPath string) (*Corpus, error) {
+	modTime, isDir, err := corpusModTime(path)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{indexPath}
+	if indexPath == "" {
+		if candidates, err = defaultIndexPaths(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, candidate := range candidates {
+		if index, err := os.Stat(candidate); err == nil && !index.ModTime().Before(modTime) {
+			return openIndex(candidate)
+		}
+		if err = buildIndex(path, isDir, candidate); err == nil {
+			return openIndex(candidate)
+		}
+		if !errors.Is(err, errNotWritable) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// corpusModTime returns when the corpus at path last changed. A directory's
+// own time only moves as files come and go, so its files' count too.
+func corpusModTime(path string) (time.Time, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if !info.IsDir() {
+		return info.ModTime(), false, nil
+	}
+
+	modTime := info.ModTime()
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return modTime, true, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return modTime, true, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, true, nil
+}
+
+// defaultIndexPaths lists where the index of the corpus at path may go, in
+// order of preference. Away from the corpus, the index is named after the
+// corpus's absolute path so that different corpora do not share one.
+func defaultIndexPaths(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := filepath.Base(abs) + "-" + hex.EncodeToString(sum[:8]) + ".idx"
+
+	paths := []string{abs + ".idx"}
+	if dir, err := os.UserCacheDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "blogy", name))
+	}
+	return append(paths, filepath.Join(os.TempDir(), "blogy", name)), nil
+}
+
+func openIndex(name string) (*Corpus, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	magic := make([]byte, len(indexMagic))
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.ReadFull(f, magic)
+	}
+	if err != nil || !bytes.Equal(magic, indexMagic) || (info.Size()-int64(len(indexMagic)))%keySize != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%s: not a breached password index", name)
+	}
+	return &Corpus{index: f, n: int((info.Size() - int64(len(indexMagic))) / keySize)}, nil
+}
+
+// buildIndex writes the index of the corpus at path to a temporary file
+// and moves it into place once complete.
+func buildIndex(path string, isDir bool, indexPath string) error {
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0o755); err != nil {
+		return fmt.Errorf("%w: %w", errNotWritable, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(indexPath), filepath.Base(indexPath)+".*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", errNotWritable, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := &indexWriter{w: bufio.NewWriter(tmp)}
+	if _, err := w.w.Write(indexMagic); err != nil {
+		return err
+	}
+	if isDir {
+		err = w.addRangeDirectory(path)
+	} else {
+		err = w.addHashFile(path, tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), indexPath)
+}
+
+// indexWriter writes keys to an index, which have to come in order. Keys
+// equal to the last one are dropped.
+type indexWriter struct {
+	w       *bufio.Writer
+	last    uint64
+	written bool
+}
+
+func (w *indexWriter) add(key uint64) error {
+	if w.written && key <= w.last {
+		if key == w.last {
+			return nil
+		}
+		return errUnsorted
+	}
+	var buf [keySize]byte
+	binary.BigEndian.PutUint64(buf[:], key)
+	w.last, w.written = key, true
+	_, err := w.w.Write(buf[:])
+	return err
+}
+
+// addRangeDirectory indexes a directory of range files. Files are taken
+// in the order of their prefixes, and since those are the top bits of
+// the keys, only one prefix's keys have to be sorted at a time.
+func (w *indexWriter) addRangeDirectory(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]string{}
+	var prefixes []string
+	for _, entry := range entries {
+		prefix := strings.TrimSuffix(entry.Name(), ".txt")
+		if entry.IsDir() || len(prefix) != prefixLength || !isHex(prefix) {
+			continue
+		}
+		prefix = strings.ToUpper(prefix)
+		if files[prefix] == nil {
+			prefixes = append(prefixes, prefix)
+		}
+		files[prefix] = append(files[prefix], filepath.Join(dir, entry.Name()))
+	}
+	slices.Sort(prefixes)
+
+	var keys []uint64
+	for _, prefix := range prefixes {
+		keys = keys[:0]
+		for _, name := range files[prefix] {
+			if err := readFile(name, prefix, func(key uint64) error {
+				keys = append(keys, key)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if err := w.add(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addHashFile indexes a file of whole hashes, straight through while they
+// are in order. If they turn out not to be, the index is started over from
+// the keys sorted in memory.
+func (w *indexWriter) addHashFile(name string, index *os.File) error {
+	err := readFile(name, "", w.add)
+	if err != errUnsorted {
+		return err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	keys := make([]uint64, 0, info.Size()/minLineLength+1)
+	if err := readFile(name, "", func(key uint64) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return err
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	if err := index.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := index.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.w.Reset(index)
+	w.written = false
+	if _, err := w.w.Write(indexMagic); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := w.add(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile passes the key of each hash in one file, whose lines hold the
+// rest of a hash after prefix, to add.
+func readFile(name, prefix string, add func(uint64) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		suffix, count, ok := strings.Cut(text, ":")
+		hash := prefix + suffix
+		if !ok || len(hash) != 2*sha1.Size || !isHex(hash) {
+			return fmt.Errorf("%s:%d: not a HIBP entry", name, line)
+		}
+		if n, err := strconv.ParseUint(strings.TrimSpace(count), 10, 64); err != nil {
+			return fmt.Errorf("%s:%d: bad count", name, line)
+		} else if n == 0 {
+			continue
+		}
+
+		key, _ := strconv.ParseUint(hash[:16], 16, 64)
+		if err := add(key); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Contains reports whether password is in the corpus. A nil corpus
+// contains nothing, and neither does one whose index cannot be read.
+func (c *Corpus) Contains(password string) bool {
+	if c == nil {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	key := binary.BigEndian.Uint64(sum[:8])
+
+	var buf [keySize]byte
+	lo, hi := 0, c.n
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if _, err := c.index.ReadAt(buf[:], int64(len(indexMagic))+int64(mid)*keySize); err != nil {
+			return false
+		}
+		switch k := binary.BigEndian.Uint64(buf[:]); {
+		case k == key:
+			return true
+		case k < key:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return false
+}
+
+// Len returns how many passwords the corpus holds.
+func (c *Corpus) Len() int {
+	if c == nil {
+		return 0
+	}
+	return c.n
+}
+
+// Close closes the corpus's index. A nil corpus has nothing to close.
+func (c *Corpus) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.index.Close()
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(hexDigits, r) {
+			return false
+		}
+	}
+	return true
+}
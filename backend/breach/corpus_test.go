@@ -0,0 +1,180 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestLoadRangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, lines ...string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\r\n")), 0o644))
+	}
+
+	password := sha1Hex("Password1!")
+	other := sha1Hex("hunter2")
+	padding := sha1Hex("padding only")
+	write(password[:5]+".txt", password[5:]+":12345")
+	write(other[:5], other[5:]+":3", "")
+	write(padding[:5]+".txt", padding[5:]+":0")
+	write("README.md", "not a range file")
+
+	corpus, err := Load(dir, "")
+	require.NoError(t, err)
+	defer corpus.Close()
+	assert.Equal(t, 2, corpus.Len())
+	assert.True(t, corpus.Contains("Password1!"))
+	assert.True(t, corpus.Contains("hunter2"))
+	assert.False(t, corpus.Contains("padding only"))
+	assert.False(t, corpus.Contains("Correct#Horse1"))
+}
+
+func TestLoadHashFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "pwned.txt")
+	lines := []string{
+		sha1Hex("Password1!") + ":12345",
+		strings.ToLower(sha1Hex("hunter2")) + ":3",
+		sha1Hex("hunter2") + ":3",
+	}
+	require.NoError(t, os.WriteFile(name, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+
+	corpus, err := Load(name, "")
+	require.NoError(t, err)
+	defer corpus.Close()
+	assert.Equal(t, 2, corpus.Len())
+	assert.True(t, corpus.Contains("Password1!"))
+	assert.True(t, corpus.Contains("hunter2"))
+	assert.False(t, corpus.Contains("hunter3"))
+}
+
+func TestLoadIndex(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "pwned.txt")
+	write := func(passwords ...string) {
+		var lines []string
+		for _, password := range passwords {
+			lines = append(lines, sha1Hex(password)+":1")
+		}
+		require.NoError(t, os.WriteFile(name, []byte(strings.Join(lines, "\n")), 0o644))
+	}
+	load := func() *Corpus {
+		corpus, err := Load(name, "")
+		require.NoError(t, err)
+		t.Cleanup(func() { corpus.Close() })
+		return corpus
+	}
+
+	// In order, as HIBP hands them out, and not.
+	passwords := []string{"Password1!", "hunter2", "letmein", "dragon", "monkey"}
+	slices.SortFunc(passwords, func(a, b string) int { return strings.Compare(sha1Hex(a), sha1Hex(b)) })
+	write(passwords...)
+	corpus := load()
+	assert.Equal(t, 5, corpus.Len())
+	for _, password := range passwords {
+		assert.True(t, corpus.Contains(password), password)
+	}
+
+	slices.Reverse(passwords)
+	write(append(passwords, passwords[0])...)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(name, later, later))
+	corpus = load()
+	assert.Equal(t, 5, corpus.Len())
+	for _, password := range passwords {
+		assert.True(t, corpus.Contains(password), password)
+	}
+	assert.False(t, corpus.Contains("Correct#Horse1"))
+
+	// The index is reused while the corpus has not changed.
+	write("hunter2")
+	earlier := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(name, earlier, earlier))
+	assert.Equal(t, 5, load().Len())
+}
+
+func TestLoadIndexLocation(t *testing.T) {
+	dir := t.TempDir()
+	password, other := sha1Hex("Password1!"), sha1Hex("hunter2")
+	otherFile := filepath.Join(dir, other[:5])
+	require.NoError(t, os.WriteFile(filepath.Join(dir, password[:5]), []byte(password[5:]+":1\n"), 0o644))
+	require.NoError(t, os.WriteFile(otherFile, []byte(other[5:]+":0\n"), 0o644))
+
+	indexPath := filepath.Join(t.TempDir(), "pwned.idx")
+	corpus, err := Load(dir, indexPath)
+	require.NoError(t, err)
+	defer corpus.Close()
+	assert.True(t, corpus.Contains("Password1!"))
+	assert.False(t, corpus.Contains("hunter2"))
+	_, err = os.Stat(dir + ".idx")
+	assert.True(t, os.IsNotExist(err), "a given index path is used instead")
+
+	// Editing a range file in place leaves the directory's own time alone
+	// but still rebuilds the index.
+	require.NoError(t, os.WriteFile(otherFile, []byte(other[5:]+":1\n"), 0o644))
+	earlier := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(dir, earlier, earlier))
+	require.NoError(t, os.Chtimes(indexPath, earlier.Add(time.Minute), earlier.Add(time.Minute)))
+
+	corpus, err = Load(dir, indexPath)
+	require.NoError(t, err)
+	defer corpus.Close()
+	assert.True(t, corpus.Contains("hunter2"))
+}
+
+func TestLoadReadOnlyCorpus(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "pwned.txt")
+	require.NoError(t, os.WriteFile(name, []byte(sha1Hex("Password1!")+":1\n"), 0o644))
+	require.NoError(t, os.Chmod(dir, 0o555))
+	t.Cleanup(func() { os.Chmod(dir, 0o755) })
+
+	corpus, err := Load(name, "")
+	require.NoError(t, err)
+	defer corpus.Close()
+	assert.True(t, corpus.Contains("Password1!"))
+
+	indexes, err := filepath.Glob(filepath.Join(cache, "blogy", "pwned.txt-*.idx"))
+	require.NoError(t, err)
+	assert.Len(t, indexes, 1, "the index goes to the cache directory")
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing"), "")
+	assert.Error(t, err)
+
+	for _, line := range []string{"nonsense", sha1Hex("x") + ":many", sha1Hex("x")[:30] + ":1"} {
+		name := filepath.Join(t.TempDir(), "pwned.txt")
+		require.NoError(t, os.WriteFile(name, []byte(line+"\n"), 0o644))
+		_, err := Load(name, "")
+		assert.Error(t, err, line)
+		_, err = os.Stat(name + ".idx")
+		assert.True(t, os.IsNotExist(err), "no index is left behind")
+	}
+}
+
+func TestNilCorpus(t *testing.T) {
+	var corpus *Corpus
+	assert.False(t, corpus.Contains("Password1!"))
+	assert.Zero(t, corpus.Len())
+	assert.NoError(t, corpus.Close())
+}
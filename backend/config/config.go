@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -15,6 +17,46 @@ type Config struct {
 	Environment    string
 	RateLimit      int
 	MaxUploadSize  int64
+
+	PublishInterval time.Duration
+
+	CommentTreeDepth        int
+	CommentRepliesPerBranch int
+	CommentEditWindow       time.Duration
+	CommentModeration       string  // open, hold_first_time, hold_all or closed
+	SpamThreshold           float64 // 0-1; comments scoring this or more are held
+
+	AdminEmail       string // promoted to admin at startup once verified
+	ImpersonationTTL time.Duration
+	SessionCacheTTL  time.Duration
+
+	JWTKeyDir     string // PEM keys named by kid; HS256 with JWTSecret if empty
+	JWTSigningKey string // kid new tokens are signed with; defaults to the last one
+
+	AppURL string // frontend base URL for links in emails
+
+	SMTPAddr     string // host:port; without it mail goes to MailDir or the console
+	SMTPUsername string
+	SMTPPassword string
+	MailDir      string
+	MailFrom     string
+
+	RequireVerifiedEmail bool
+
+	LoginMaxFailures     int
+	LoginMaxIPFailures   int
+	LoginLockoutDuration time.Duration
+	LoginBaseDelay       time.Duration // doubles per failure up to LoginMaxDelay
+	LoginMaxDelay        time.Duration
+
+	PasswordHasher    string // argon2id or bcrypt
+	Argon2Memory      int    // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+
+	BreachedPasswordsPath  string // HIBP range directory or hash file
+	BreachedPasswordsIndex string // defaults to the path with .idx added, or the cache directory
 }
 
 func Load() *Config {
@@ -28,7 +70,72 @@ func Load() *Config {
 		Environment:    getEnvOrDefault("ENV", "development"),
 		RateLimit:      100,     // requests per minute
 		MaxUploadSize:  5 << 20, // 5MB
+
+		PublishInterval: 30 * time.Second,
+
+		CommentTreeDepth:        3,
+		CommentRepliesPerBranch: 5,
+		CommentEditWindow:       15 * time.Minute,
+		CommentModeration:       getEnvOrDefault("COMMENT_MODERATION", "open"),
+		SpamThreshold:           0.8,
+
+		AdminEmail:       os.Getenv("ADMIN_EMAIL"),
+		ImpersonationTTL: 15 * time.Minute,
+		SessionCacheTTL:  10 * time.Second,
+
+		JWTKeyDir:     os.Getenv("JWT_KEY_DIR"),
+		JWTSigningKey: os.Getenv("JWT_SIGNING_KEY"),
+
+		AppURL: getEnvOrDefault("APP_URL", "http://localhost:5173"),
+
+		SMTPAddr:     os.Getenv("SMTP_ADDR"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailDir:      os.Getenv("MAIL_DIR"),
+		MailFrom:     getEnvOrDefault("MAIL_FROM", "Blogy <no-reply@localhost>"),
+
+		RequireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+
+		LoginMaxFailures:     10,
+		LoginMaxIPFailures:   100,
+		LoginLockoutDuration: 15 * time.Minute,
+		LoginBaseDelay:       time.Second,
+		LoginMaxDelay:        time.Minute,
+
+		PasswordHasher:    getEnvOrDefault("PASSWORD_HASHER", "argon2id"),
+		Argon2Memory:      getEnvInt("ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 3),
+		Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 4),
+		BcryptCost:        getEnvInt("BCRYPT_COST", 12),
+
+		BreachedPasswordsPath:  os.Getenv("BREACHED_PASSWORDS_PATH"),
+		BreachedPasswordsIndex: os.Getenv("BREACHED_PASSWORDS_INDEX"),
+	}
+}
+
+// Validate reports settings the server must not start with.
+func (c *Config) Validate() error {
+	if c.Environment == "production" && c.JWTKeyDir == "" && c.JWTSecret == "" {
+		return errors.New("JWT_SECRET or JWT_KEY_DIR must be set in production")
+	}
+	if c.PasswordHasher != "argon2id" && c.PasswordHasher != "bcrypt" {
+		return errors.New("PASSWORD_HASHER must be argon2id or bcrypt")
 	}
+	if c.Argon2Parallelism < 1 || c.Argon2Parallelism > 255 {
+		return errors.New("ARGON2_PARALLELISM must be between 1 and 255")
+	}
+	// Argon2 needs 8 KiB per lane; 4 GiB is far beyond any sane setting.
+	if c.Argon2Memory < 8*c.Argon2Parallelism || c.Argon2Memory > 4<<20 {
+		return errors.New("ARGON2_MEMORY must be between 8 KiB per lane and 4 GiB, in KiB")
+	}
+	if c.Argon2Iterations < 1 || c.Argon2Iterations > 100 {
+		return errors.New("ARGON2_ITERATIONS must be between 1 and 100")
+	}
+	// The range bcrypt accepts.
+	if c.BcryptCost < 4 || c.BcryptCost > 31 {
+		return errors.New("BCRYPT_COST must be between 4 and 31")
+	}
+	return nil
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -37,3 +144,17 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt returns -1 for a value that is not a number, for Validate to
+// refuse.
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return n
+}
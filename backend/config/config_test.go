@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHasherSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"defaults", nil, ""},
+		{"bcrypt", map[string]string{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "10"}, ""},
+		{"tuned argon2id", map[string]string{"ARGON2_MEMORY": "19456", "ARGON2_ITERATIONS": "2", "ARGON2_PARALLELISM": "1"}, ""},
+		{"unknown hasher", map[string]string{"PASSWORD_HASHER": "md5"}, "PASSWORD_HASHER"},
+		{"not a number", map[string]string{"ARGON2_MEMORY": "64MiB"}, "ARGON2_MEMORY"},
+		{"too little memory", map[string]string{"ARGON2_MEMORY": "16", "ARGON2_PARALLELISM": "4"}, "ARGON2_MEMORY"},
+		{"no iterations", map[string]string{"ARGON2_ITERATIONS": "0"}, "ARGON2_ITERATIONS"},
+		{"too many lanes", map[string]string{"ARGON2_PARALLELISM": "256"}, "ARGON2_PARALLELISM"},
+		{"bcrypt cost too low", map[string]string{"BCRYPT_COST": "3"}, "BCRYPT_COST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			err := Load().Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 type Database struct {
 	*sql.DB
+	SearchEnabled bool
 }
 
 type Config struct {
@@ -39,7 +40,12 @@ func NewDatabase(dbPath string, config *Config) (*Database, error) {
 		return nil, fmt.Errorf("error initializing schema: %w", err)
 	}
 
-	return &Database{db}, nil
+	searchEnabled, err := initializeSearch(db)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing search: %w", err)
+	}
+
+	return &Database{DB: db, SearchEnabled: searchEnabled}, nil
 }
 
 func (db *Database) WithTx(fn func(*sql.Tx) error) error {
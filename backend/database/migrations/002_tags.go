@@ -0,0 +1,7 @@
+package migrations
+
+// Tag names are normalized before they are stored, the case-insensitive
+// index is a backstop against duplicates slipping in some other way.
+const tagsSchema = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name_nocase ON tags(name COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_post_tags_post_id ON post_tags(post_id);`
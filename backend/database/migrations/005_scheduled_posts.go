@@ -0,0 +1,7 @@
+package migrations
+
+// publish_at is stored in UTC so it compares correctly as text.
+const scheduledPostsSchema = `
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_publish_at ON posts(status, publish_at);`
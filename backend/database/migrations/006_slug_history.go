@@ -0,0 +1,13 @@
+package migrations
+
+// slug_history keeps every slug a post has given up so that old links can
+// be redirected to the current one.
+const slugHistorySchema = `
+CREATE TABLE IF NOT EXISTS slug_history (
+    slug TEXT PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_slug_history_post_id ON slug_history(post_id);`
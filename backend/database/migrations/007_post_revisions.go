@@ -0,0 +1,20 @@
+package migrations
+
+// Existing posts get their current state as revision 1.
+const postRevisionsSchema = `
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (post_id, revision),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO post_revisions (post_id, revision, user_id, title, content, created_at)
+SELECT id, 1, user_id, title, content, COALESCE(updated_at, created_at)
+FROM posts;`
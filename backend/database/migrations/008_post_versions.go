@@ -0,0 +1,6 @@
+package migrations
+
+// version is bumped on every write to a post and backs the ETag used for
+// optimistic concurrency control.
+const postVersionsSchema = `
+ALTER TABLE posts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`
@@ -0,0 +1,6 @@
+package migrations
+
+// content_html holds the sanitized rendering of content. Rows that predate
+// this column are rendered by handlers.RenderPendingPosts at startup.
+const postContentHTMLSchema = `
+ALTER TABLE posts ADD COLUMN content_html TEXT;`
@@ -0,0 +1,9 @@
+package migrations
+
+// Comments that are deleted while they still have replies keep their row,
+// with deleted_at set, so the replies stay attached to the thread.
+const commentThreadsSchema = `
+ALTER TABLE comments ADD COLUMN parent_id INTEGER REFERENCES comments(id);
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);`
@@ -0,0 +1,16 @@
+package migrations
+
+// comment_revisions keeps the content a comment had before each edit.
+// created_at is when that content was written, not when it was replaced.
+const commentEditsSchema = `
+ALTER TABLE comments ADD COLUMN updated_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions(comment_id);`
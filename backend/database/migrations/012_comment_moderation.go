@@ -0,0 +1,10 @@
+package migrations
+
+// comment_mode overrides the site-wide moderation mode for one post when
+// set; comments_locked lets the author shut comments off entirely.
+const commentModerationSchema = `
+ALTER TABLE comments ADD COLUMN status TEXT NOT NULL DEFAULT 'approved';
+ALTER TABLE posts ADD COLUMN comment_mode TEXT;
+ALTER TABLE posts ADD COLUMN comments_locked BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_comments_status ON comments(status, created_at);`
@@ -0,0 +1,28 @@
+package migrations
+
+// spam_tokens and spam_documents hold the naive Bayes model trained from
+// moderation decisions; spam_fingerprints remembers recently seen content
+// for duplicate detection.
+const spamSchema = `
+ALTER TABLE comments ADD COLUMN spam_score REAL;
+
+CREATE TABLE IF NOT EXISTS spam_tokens (
+    token TEXT PRIMARY KEY,
+    spam_count INTEGER NOT NULL DEFAULT 0,
+    ham_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spam_documents (
+    label TEXT PRIMARY KEY CHECK (label IN ('spam', 'ham')),
+    count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spam_fingerprints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_spam_fingerprints_hash ON spam_fingerprints(hash, created_at);
+CREATE INDEX IF NOT EXISTS idx_spam_fingerprints_created_at ON spam_fingerprints(created_at);`
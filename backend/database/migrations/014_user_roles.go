@@ -0,0 +1,7 @@
+package migrations
+
+// Existing accounts become authors so that everyone who could write posts
+// before roles existed still can.
+const userRolesSchema = `
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'author'
+    CHECK (role IN ('user', 'author', 'moderator', 'admin'));`
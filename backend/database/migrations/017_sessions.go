@@ -0,0 +1,25 @@
+package migrations
+
+// A session is one sign-in: the refresh token family it started plus where
+// it came from. Families issued before sessions existed are backfilled so
+// that they show up, and can be revoked, like any other.
+const sessionsSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    family_id TEXT NOT NULL UNIQUE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+INSERT INTO sessions (user_id, family_id, created_at, last_seen_at, revoked_at)
+SELECT user_id, family_id, MIN(created_at), MAX(created_at),
+       CASE WHEN SUM(used_at IS NULL AND revoked_at IS NULL) = 0 THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY user_id, family_id;`
@@ -0,0 +1,13 @@
+package migrations
+
+// login_throttles counts recent failed logins per account and per client
+// address, and holds when the next attempt is allowed.
+const loginThrottlesSchema = `
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    blocked_until TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure_at ON login_throttles(last_failure_at);`
@@ -0,0 +1,13 @@
+package migrations
+
+// published_at is when a post last went live, which is what listings and
+// feeds order by: a scheduled post keeps the ID and created_at it was
+// drafted with. Posts already published are backfilled from their
+// schedule, or failing that their creation time.
+const publishedAtSchema = `
+ALTER TABLE posts ADD COLUMN published_at TIMESTAMP;
+
+UPDATE posts SET published_at = COALESCE(publish_at, created_at)
+WHERE status = 'published';
+
+CREATE INDEX IF NOT EXISTS idx_posts_status_published_at ON posts(status, published_at, id);`
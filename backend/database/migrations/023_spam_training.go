@@ -0,0 +1,10 @@
+package migrations
+
+// spam_trained remembers what each moderated document taught the spam
+// model, so that a reversed decision can be taken back.
+const spamTrainingSchema = `
+CREATE TABLE IF NOT EXISTS spam_trained (
+    id INTEGER PRIMARY KEY,
+    label TEXT NOT NULL CHECK (label IN ('spam', 'ham')),
+    tokens TEXT NOT NULL
+);`
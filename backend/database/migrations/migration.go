@@ -17,6 +17,116 @@ var migrations = []Migration{
 		Description: "Initial schema",
 		SQL:         initialSchema,
 	},
+	{
+		Version:     2,
+		Description: "Case-insensitive tag names",
+		SQL:         tagsSchema,
+	},
+	{
+		Version:     3,
+		Description: "Comment likes",
+		SQL:         commentLikesSchema,
+	},
+	{
+		Version:     4,
+		Description: "Follow graph",
+		SQL:         followsSchema,
+	},
+	{
+		Version:     5,
+		Description: "Scheduled publishing",
+		SQL:         scheduledPostsSchema,
+	},
+	{
+		Version:     6,
+		Description: "Slug history",
+		SQL:         slugHistorySchema,
+	},
+	{
+		Version:     7,
+		Description: "Post revisions",
+		SQL:         postRevisionsSchema,
+	},
+	{
+		Version:     8,
+		Description: "Post versions",
+		SQL:         postVersionsSchema,
+	},
+	{
+		Version:     9,
+		Description: "Rendered post content",
+		SQL:         postContentHTMLSchema,
+	},
+	{
+		Version:     10,
+		Description: "Threaded comments",
+		SQL:         commentThreadsSchema,
+	},
+	{
+		Version:     11,
+		Description: "Comment edit history",
+		SQL:         commentEditsSchema,
+	},
+	{
+		Version:     12,
+		Description: "Comment moderation",
+		SQL:         commentModerationSchema,
+	},
+	{
+		Version:     13,
+		Description: "Spam classifier",
+		SQL:         spamSchema,
+	},
+	{
+		Version:     14,
+		Description: "User roles",
+		SQL:         userRolesSchema,
+	},
+	{
+		Version:     15,
+		Description: "Account status and admin audit log",
+		SQL:         accountStatusSchema,
+	},
+	{
+		Version:     16,
+		Description: "Refresh tokens",
+		SQL:         refreshTokensSchema,
+	},
+	{
+		Version:     17,
+		Description: "Sessions",
+		SQL:         sessionsSchema,
+	},
+	{
+		Version:     18,
+		Description: "Two-factor authentication",
+		SQL:         twoFactorSchema,
+	},
+	{
+		Version:     19,
+		Description: "Password resets",
+		SQL:         passwordResetsSchema,
+	},
+	{
+		Version:     20,
+		Description: "Email verification",
+		SQL:         emailVerificationSchema,
+	},
+	{
+		Version:     21,
+		Description: "Login throttling",
+		SQL:         loginThrottlesSchema,
+	},
+	{
+		Version:     22,
+		Description: "Post publication time",
+		SQL:         publishedAtSchema,
+	},
+	{
+		Version:     23,
+		Description: "Spam training history",
+		SQL:         spamTrainingSchema,
+	},
 }
 
 func RunMigrations(db *sql.DB) error {
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag, so
+// the search index is set up separately from the core schema and skipped
+// when the module is missing.
+
+const postsFTSTable = `CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+	title,
+	content,
+	tags,
+	author,
+	tokenize = 'unicode61 remove_diacritics 2'
+);`
+
+const postsFTSRow = `
+	(SELECT COALESCE(group_concat(t.name, ' '), '')
+	 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	 WHERE pt.post_id = %[1]s),
+	(SELECT username FROM users WHERE id = %[2]s)`
+
+var searchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_insert
+	AFTER INSERT ON posts
+	BEGIN
+		INSERT INTO posts_fts (rowid, title, content, tags, author)
+		VALUES (NEW.id, NEW.title, NEW.content,` + fmt.Sprintf(postsFTSRow, "NEW.id", "NEW.user_id") + `);
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_update
+	AFTER UPDATE OF title, content, user_id ON posts
+	BEGIN
+		DELETE FROM posts_fts WHERE rowid = OLD.id;
+		INSERT INTO posts_fts (rowid, title, content, tags, author)
+		VALUES (NEW.id, NEW.title, NEW.content,` + fmt.Sprintf(postsFTSRow, "NEW.id", "NEW.user_id") + `);
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_delete
+	AFTER DELETE ON posts
+	BEGIN
+		DELETE FROM posts_fts WHERE rowid = OLD.id;
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_tag_insert
+	AFTER INSERT ON post_tags
+	BEGIN
+		UPDATE posts_fts SET tags = (
+			SELECT COALESCE(group_concat(t.name, ' '), '')
+			FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = NEW.post_id
+		) WHERE rowid = NEW.post_id;
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_tag_delete
+	AFTER DELETE ON post_tags
+	BEGIN
+		UPDATE posts_fts SET tags = (
+			SELECT COALESCE(group_concat(t.name, ' '), '')
+			FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = OLD.post_id
+		) WHERE rowid = OLD.post_id;
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_tag_rename
+	AFTER UPDATE OF name ON tags
+	BEGIN
+		UPDATE posts_fts SET tags = (
+			SELECT COALESCE(group_concat(t.name, ' '), '')
+			FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.post_id = posts_fts.rowid
+		) WHERE rowid IN (SELECT post_id FROM post_tags WHERE tag_id = NEW.id);
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_author_rename
+	AFTER UPDATE OF username ON users
+	BEGIN
+		UPDATE posts_fts SET author = NEW.username
+		WHERE rowid IN (SELECT id FROM posts WHERE user_id = NEW.id);
+	END;`,
+}
+
+var searchTriggerNames = []string{
+	"posts_fts_insert",
+	"posts_fts_update",
+	"posts_fts_delete",
+	"posts_fts_tag_insert",
+	"posts_fts_tag_delete",
+	"posts_fts_tag_rename",
+	"posts_fts_author_rename",
+}
+
+// FTS5Available reports whether the linked SQLite was built with FTS5.
+func FTS5Available(db *sql.DB) bool {
+	var enabled bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	return err == nil && enabled
+}
+
+func initializeSearch(db *sql.DB) (bool, error) {
+	if !FTS5Available(db) {
+		// Triggers left behind by an FTS5-enabled build would make every
+		// write to posts fail with "no such module", so drop them.
+		for _, name := range searchTriggerNames {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS " + name); err != nil {
+				return false, fmt.Errorf("error dropping search trigger: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	var existing int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'posts_fts_%'",
+	).Scan(&existing)
+	if err != nil {
+		return false, fmt.Errorf("error checking search triggers: %w", err)
+	}
+
+	if _, err := db.Exec(postsFTSTable); err != nil {
+		return false, fmt.Errorf("error creating search index: %w", err)
+	}
+
+	for _, trigger := range searchTriggers {
+		if _, err := db.Exec(trigger); err != nil {
+			return false, fmt.Errorf("error creating search trigger: %w", err)
+		}
+	}
+
+	// The index may be missing or stale if the triggers were absent, so
+	// rebuild it from scratch in that case.
+	if existing != len(searchTriggers) {
+		if err := RebuildSearchIndex(db); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// RebuildSearchIndex repopulates posts_fts from the posts table.
+func RebuildSearchIndex(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM posts_fts"); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error clearing search index: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO posts_fts (rowid, title, content, tags, author)
+		SELECT p.id, p.title, p.content,` + fmt.Sprintf(postsFTSRow, "p.id", "p.user_id") + `
+		FROM posts p
+	`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error rebuilding search index: %w", err)
+	}
+
+	return tx.Commit()
+}
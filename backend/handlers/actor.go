@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/policy"
+)
+
+// actorFrom returns the caller as the policy layer sees them. Anonymous
+// callers have no user ID and no role, so they are granted nothing.
+func actorFrom(c *gin.Context) policy.Actor {
+	return policy.Actor{UserID: c.GetInt64("user_id"), Role: c.GetString("role")}
+}
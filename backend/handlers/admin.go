@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// Actions recorded in the admin audit log.
+const (
+	auditSuspend     = "suspend"
+	auditUnsuspend   = "unsuspend"
+	auditBan         = "ban"
+	auditUnban       = "unban"
+	auditRoleChange  = "role_change"
+	auditForceLogout = "force_logout"
+	auditImpersonate = "impersonate"
+)
+
+const accountColumns = `id, username, email, role, suspended_until, banned_at,
+	COALESCE(status_reason, ''), tokens_revoked_at, created_at`
+
+// AdminHandler is the user management API. Every change it makes to an
+// account is written to the audit log in the same transaction.
+type AdminHandler struct {
+	db               *sql.DB
+	keys             *signing.Keys
+	impersonationTTL time.Duration
+	sessions         SessionCache
+}
+
+func NewAdminHandler(db *sql.DB, keys *signing.Keys, impersonationTTL time.Duration, sessions SessionCache) *AdminHandler {
+	return &AdminHandler{db: db, keys: keys, impersonationTTL: impersonationTTL, sessions: sessions}
+}
+
+// SearchUsers lists accounts, newest first. ?q= matches the username or
+// email, ?role= and ?status= (active, suspended or banned) narrow the list.
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	now := time.Now().UTC()
+	where := "1 = 1"
+	var args []interface{}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		where += ` AND (username LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern)
+	}
+
+	if role := c.Query("role"); role != "" {
+		if !models.ValidRole(role) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role")
+			return
+		}
+		where += " AND role = ?"
+		args = append(args, role)
+	}
+
+	switch c.Query("status") {
+	case "":
+	case models.AccountActive:
+		where += " AND banned_at IS NULL AND (suspended_until IS NULL OR suspended_until <= ?)"
+		args = append(args, now)
+	case models.AccountSuspended:
+		where += " AND banned_at IS NULL AND suspended_until > ?"
+		args = append(args, now)
+	case models.AccountBanned:
+		where += " AND banned_at IS NOT NULL"
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+
+	var total int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&total); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+accountColumns+`
+		FROM users
+		WHERE `+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, offset)...)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	defer rows.Close()
+
+	accounts := []*models.AccountDetails{}
+	for rows.Next() {
+		account, err := scanAccount(rows, now)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+			return
+		}
+		accounts = append(accounts, account)
+	}
+
+	utils.PaginatedSuccessResponse(c, accounts, total, page, pageSize)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	targetID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	h.respondWithAccount(c, targetID)
+}
+
+// SuspendUser locks an account out until the given time. Its existing
+// tokens stop working straight away.
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.SuspendInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	until := input.Until.UTC()
+	if !until.After(time.Now()) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Suspension must end in the future")
+		return
+	}
+
+	details := "until " + until.Format(time.RFC3339)
+	if input.Reason != "" {
+		details += ": " + input.Reason
+	}
+
+	h.updateAccount(c, targetID, auditSuspend, details,
+		"suspended_until = ?, status_reason = ?", until, input.Reason)
+}
+
+func (h *AdminHandler) UnsuspendUser(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	h.updateAccount(c, targetID, auditUnsuspend, "",
+		"suspended_until = NULL, status_reason = CASE WHEN banned_at IS NULL THEN NULL ELSE status_reason END")
+}
+
+// BanUser locks an account out until it is unbanned.
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.BanInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	h.updateAccount(c, targetID, auditBan, input.Reason,
+		"banned_at = COALESCE(banned_at, ?), status_reason = ?", time.Now().UTC(), input.Reason)
+}
+
+// UnbanUser lifts a ban. A suspension that is still running stays in
+// force.
+func (h *AdminHandler) UnbanUser(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	h.updateAccount(c, targetID, auditUnban, "",
+		"banned_at = NULL, status_reason = CASE WHEN suspended_until > ? THEN status_reason ELSE NULL END",
+		time.Now().UTC())
+}
+
+func (h *AdminHandler) ChangeRole(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	account, err := h.getAccount(targetID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.updateAccount(c, targetID, auditRoleChange, account.Role+" -> "+input.Role,
+		"role = ?", input.Role)
+}
+
+// ForceLogout ends every session of the user. Signing in again works as
+// usual.
+func (h *AdminHandler) ForceLogout(c *gin.Context) {
+	targetID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	h.changeAccount(c, targetID, auditForceLogout, "", func(tx *sql.Tx) (bool, error) {
+		return revokeAllTokens(tx, targetID)
+	})
+}
+
+// Impersonate issues a short-lived, non-refreshable access token that acts
+// as another user and names the administrator behind it.
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	targetID, ok := h.otherUserParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.ImpersonationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	account, err := h.getAccount(targetID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if account.Role == models.RoleAdmin {
+		utils.ErrorResponse(c, http.StatusForbidden, "Administrators cannot be impersonated")
+		return
+	}
+	if account.Status != models.AccountActive {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Account is not active")
+		return
+	}
+
+	expiresAt := now.Add(h.impersonationTTL)
+	token, err := h.keys.Sign(jwt.MapClaims{
+		"user_id":         targetID,
+		"role":            account.Role,
+		"type":            "access",
+		"impersonator_id": c.GetInt64("user_id"),
+		"iat":             now.Unix(),
+		"exp":             expiresAt.Unix(),
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed")
+		return
+	}
+
+	details := fmt.Sprintf("until %s: %s", expiresAt.Format(time.RFC3339), input.Reason)
+	if err := recordAudit(h.db, c.GetInt64("user_id"), auditImpersonate, targetID, details); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to record impersonation")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"user":        account,
+		"accessToken": token,
+		"tokenType":   "Bearer",
+		"expiresIn":   int(h.impersonationTTL.Seconds()),
+		"expiresAt":   expiresAt,
+	})
+}
+
+// GetAuditLog lists administrative actions, newest first, optionally only
+// those on ?user_id=.
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	where := "1 = 1"
+	var args []interface{}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		where += " AND l.target_user_id = ?"
+		args = append(args, userID)
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+
+	var total int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM admin_audit_log l WHERE "+where, args...).Scan(&total); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT l.id, l.admin_id, u.username, l.action, l.target_user_id, l.details, l.created_at
+		FROM admin_audit_log l
+		JOIN users u ON u.id = l.admin_id
+		WHERE `+where+`
+		ORDER BY l.created_at DESC, l.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, offset)...)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry := &models.AuditEntry{}
+		err := rows.Scan(&entry.ID, &entry.AdminID, &entry.AdminUsername, &entry.Action,
+			&entry.TargetUserID, &entry.Details, &entry.CreatedAt)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit log")
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	utils.PaginatedSuccessResponse(c, entries, total, page, pageSize)
+}
+
+// userIDParam parses :id, writing the error response itself when it
+// returns false.
+func userIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// otherUserParam is userIDParam for actions an administrator must not take
+// on their own account, so that nobody locks themselves out.
+func (h *AdminHandler) otherUserParam(c *gin.Context) (int64, bool) {
+	id, ok := userIDParam(c)
+	if ok && id == c.GetInt64("user_id") {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot do this to your own account")
+		return 0, false
+	}
+	return id, ok
+}
+
+// updateAccount sets columns on the user targetID through changeAccount.
+func (h *AdminHandler) updateAccount(c *gin.Context, targetID int64, action, details, set string, args ...interface{}) {
+	h.changeAccount(c, targetID, action, details, func(tx *sql.Tx) (bool, error) {
+		result, err := tx.Exec("UPDATE users SET "+set+", updated_at = ? WHERE id = ?",
+			append(args, time.Now().UTC(), targetID)...)
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	})
+}
+
+// changeAccount runs change on targetID, audits action and responds with the
+// account; change reports whether the user exists.
+func (h *AdminHandler) changeAccount(c *gin.Context, targetID int64, action, details string, change func(tx *sql.Tx) (bool, error)) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	found, err := change(tx)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := recordAudit(tx, c.GetInt64("user_id"), action, targetID, details); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	if h.sessions != nil {
+		h.sessions.Forget(targetID)
+	}
+
+	h.respondWithAccount(c, targetID)
+}
+
+func recordAudit(exec execer, adminID int64, action string, targetID int64, details string) error {
+	_, err := exec.Exec(`
+		INSERT INTO admin_audit_log (admin_id, action, target_user_id, details, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, adminID, action, targetID, details, time.Now().UTC())
+	return err
+}
+
+func (h *AdminHandler) respondWithAccount(c *gin.Context, userID int64) {
+	account, err := h.getAccount(userID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+
+	utils.SuccessResponse(c, account)
+}
+
+func (h *AdminHandler) getAccount(userID int64) (*models.AccountDetails, error) {
+	row := h.db.QueryRow("SELECT "+accountColumns+" FROM users WHERE id = ?", userID)
+	return scanAccount(row, time.Now())
+}
+
+func scanAccount(row rowScanner, now time.Time) (*models.AccountDetails, error) {
+	account := &models.AccountDetails{}
+	var suspendedUntil, bannedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&account.ID,
+		&account.Username,
+		&account.Email,
+		&account.Role,
+		&suspendedUntil,
+		&bannedAt,
+		&account.StatusReason,
+		&revokedAt,
+		&account.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if suspendedUntil.Valid {
+		account.SuspendedUntil = &suspendedUntil.Time
+	}
+	if bannedAt.Valid {
+		account.BannedAt = &bannedAt.Time
+	}
+	if revokedAt.Valid {
+		account.TokensRevokedAt = &revokedAt.Time
+	}
+	account.SetStatus(now)
+	return account, nil
+}
+
+// PromoteAdmin makes the verified account with email an admin and reports
+// whether there is one. Unverified addresses could belong to anyone.
+func PromoteAdmin(db *sql.DB, email string) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE users SET role = ? WHERE email = ? AND email_verified_at IS NOT NULL",
+		models.RoleAdmin, email,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
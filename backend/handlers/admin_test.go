@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	keys := signing.NewHMAC("test-secret")
+	db := newTestDatabase(t)
+	adminID := insertTestUser(t, db, "admin")
+	aliceID := insertTestUser(t, db, "alice")
+	bobID := insertTestUser(t, db, "bob")
+	found, err := PromoteAdmin(db.DB, "admin@example.com")
+	require.NoError(t, err)
+	require.False(t, found, "unverified addresses are not promoted")
+	_, err = db.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", adminID)
+	require.NoError(t, err)
+	found, err = PromoteAdmin(db.DB, "admin@example.com")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	gin.SetMode(gin.TestMode)
+	authenticator := middleware.NewAuthenticator(keys, db.DB, time.Minute)
+	auth := NewAuthHandler(db.DB, AuthOptions{Keys: keys, Sessions: authenticator})
+	admin := NewAdminHandler(db.DB, keys, 15*time.Minute, authenticator)
+
+	router := gin.New()
+	protected := router.Group("", middleware.AuthMiddleware(authenticator))
+	protected.GET("/whoami", func(c *gin.Context) {
+		utils.SuccessResponse(c, gin.H{
+			"user_id":         c.GetInt64("user_id"),
+			"role":            c.GetString("role"),
+			"impersonator_id": c.GetInt64("impersonator_id"),
+		})
+	})
+	group := protected.Group("/admin", middleware.RequirePermission(policy.ManageUsers))
+	group.GET("/users", admin.SearchUsers)
+	group.POST("/users/:id/suspend", admin.SuspendUser)
+	group.DELETE("/users/:id/suspend", admin.UnsuspendUser)
+	group.POST("/users/:id/ban", admin.BanUser)
+	group.PUT("/users/:id/role", admin.ChangeRole)
+	group.POST("/users/:id/logout", admin.ForceLogout)
+	group.POST("/users/:id/impersonate", admin.Impersonate)
+	group.GET("/audit-log", admin.GetAuditLog)
+
+	tokenFor := func(userID int64) string {
+		t.Helper()
+		access, _, err := auth.generateTokenPair(userID, "", "test-agent", "127.0.0.1")
+		require.NoError(t, err)
+		return access
+	}
+	request := func(token, method, url string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		return doJSONWithToken(router, token, method, url, body)
+	}
+
+	adminToken := tokenFor(adminID)
+	aliceToken := tokenFor(aliceID)
+	bobToken := tokenFor(bobID)
+
+	assert.Equal(t, http.StatusForbidden, request(aliceToken, "GET", "/admin/users", nil).Code)
+
+	var page struct {
+		Items      []models.AccountDetails `json:"items"`
+		TotalItems int64                   `json:"total_items"`
+	}
+	w := request(adminToken, "GET", "/admin/users?q=ali", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decodeData(t, w, &page)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, aliceID, page.Items[0].ID)
+	assert.Equal(t, models.AccountActive, page.Items[0].Status)
+
+	// Suspensions and bans take effect on tokens already handed out.
+	until := time.Now().Add(time.Hour)
+	w = request(adminToken, "POST", "/admin/users/2/suspend", gin.H{"until": until, "reason": "cool off"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var account models.AccountDetails
+	decodeData(t, w, &account)
+	assert.Equal(t, models.AccountSuspended, account.Status)
+	assert.Equal(t, http.StatusForbidden, request(aliceToken, "GET", "/whoami", nil).Code)
+
+	require.Equal(t, http.StatusOK, request(adminToken, "DELETE", "/admin/users/2/suspend", nil).Code)
+	assert.Equal(t, http.StatusOK, request(aliceToken, "GET", "/whoami", nil).Code)
+
+	require.Equal(t, http.StatusOK, request(adminToken, "POST", "/admin/users/3/ban", gin.H{"reason": "spam"}).Code)
+	assert.Equal(t, http.StatusForbidden, request(bobToken, "GET", "/whoami", nil).Code)
+	decodeData(t, request(adminToken, "GET", "/admin/users?status=banned", nil), &page)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, bobID, page.Items[0].ID)
+
+	// Administrators cannot lock themselves out.
+	assert.Equal(t, http.StatusBadRequest, request(adminToken, "POST", "/admin/users/1/ban", gin.H{}).Code)
+	assert.Equal(t, http.StatusBadRequest, request(adminToken, "PUT", "/admin/users/1/role", gin.H{"role": "user"}).Code)
+
+	// Role changes apply to existing tokens.
+	assert.Equal(t, http.StatusBadRequest, request(adminToken, "PUT", "/admin/users/2/role", gin.H{"role": "owner"}).Code)
+	require.Equal(t, http.StatusOK, request(adminToken, "PUT", "/admin/users/2/role", gin.H{"role": "moderator"}).Code)
+	var caller struct {
+		UserID         int64  `json:"user_id"`
+		Role           string `json:"role"`
+		ImpersonatorID int64  `json:"impersonator_id"`
+	}
+	w = request(aliceToken, "GET", "/whoami", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &caller)
+	assert.Equal(t, models.RoleModerator, caller.Role)
+
+	// Impersonation tokens act as the user and name the administrator.
+	assert.Equal(t, http.StatusBadRequest, request(adminToken, "POST", "/admin/users/2/impersonate", gin.H{"reason": "short"}).Code)
+	assert.Equal(t, http.StatusBadRequest, request(adminToken, "POST", "/admin/users/3/impersonate", gin.H{"reason": "reproduce a bug report"}).Code)
+	w = request(adminToken, "POST", "/admin/users/2/impersonate", gin.H{"reason": "reproduce a bug report"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var impersonation struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresIn   int    `json:"expiresIn"`
+	}
+	decodeData(t, w, &impersonation)
+	assert.Equal(t, 900, impersonation.ExpiresIn)
+
+	w = request(impersonation.AccessToken, "GET", "/whoami", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &caller)
+	assert.Equal(t, aliceID, caller.UserID)
+	assert.Equal(t, adminID, caller.ImpersonatorID)
+
+	// The token dies with the administrator's right to impersonate.
+	_, err = db.Exec("UPDATE users SET role = 'moderator' WHERE id = ?", adminID)
+	require.NoError(t, err)
+	authenticator.Forget(adminID)
+	assert.Equal(t, http.StatusUnauthorized, request(impersonation.AccessToken, "GET", "/whoami", nil).Code)
+	_, err = db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	require.NoError(t, err)
+	authenticator.Forget(adminID)
+
+	require.Equal(t, http.StatusOK, request(adminToken, "POST", "/admin/users/2/logout", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(aliceToken, "GET", "/whoami", nil).Code)
+
+	var audit struct {
+		Items []models.AuditEntry `json:"items"`
+	}
+	decodeData(t, request(adminToken, "GET", "/admin/audit-log?user_id=2", nil), &audit)
+	var actions []string
+	for _, entry := range audit.Items {
+		assert.Equal(t, "admin", entry.AdminUsername)
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{"force_logout", "impersonate", "role_change", "unsuspend", "suspend"}, actions)
+}
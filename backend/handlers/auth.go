@@ -3,26 +3,82 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prem0x01/Blogy/breach"
+	"github.com/prem0x01/Blogy/mail"
+	"github.com/prem0x01/Blogy/middleware"
 	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/passhash"
+	"github.com/prem0x01/Blogy/signing"
 	"github.com/prem0x01/Blogy/utils"
 )
 
+// AuthOptions configures AuthHandler.
+type AuthOptions struct {
+	// Keys signs the tokens handed out.
+	Keys *signing.Keys
+	// Sessions is told about users whose sessions end; it may be nil.
+	Sessions SessionCache
+	// Mailer sends password reset links, which point into the frontend at
+	// AppURL.
+	Mailer mail.Mailer
+	AppURL string
+	// LoginLimits throttles failed logins; the zero value does not.
+	LoginLimits LoginLimits
+	// Hasher hashes new passwords, and replaces older hashes as their
+	// users log in. It defaults to Argon2id with the default parameters.
+	Hasher passhash.Hasher
+	// BreachedPasswords are refused as new passwords; it may be nil.
+	BreachedPasswords *breach.Corpus
+}
+
 type AuthHandler struct {
-	db        *sql.DB
-	jwtSecret string
+	db       *sql.DB
+	keys     *signing.Keys
+	sessions SessionCache
+	mailer   mail.Mailer
+	appURL   string
+
+	loginLimits LoginLimits
+	hasher      passhash.Hasher
+	breached    *breach.Corpus
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthHandler(db *sql.DB, jwtSecret string) *AuthHandler {
+func NewAuthHandler(db *sql.DB, opts AuthOptions) *AuthHandler {
+	if opts.Hasher == nil {
+		opts.Hasher = passhash.NewArgon2id(passhash.DefaultArgon2idParams)
+	}
 	return &AuthHandler{
-		db:        db,
-		jwtSecret: jwtSecret,
+		db:       db,
+		keys:     opts.Keys,
+		sessions: opts.Sessions,
+		mailer:   opts.Mailer,
+		appURL:   strings.TrimSuffix(opts.AppURL, "/"),
+
+		loginLimits: opts.LoginLimits,
+		hasher:      opts.Hasher,
+		breached:    opts.BreachedPasswords,
 	}
 }
 
+// dummyPasswordHash is a hash of no one's password, for logins that match
+// no account to be checked against.
+func (h *AuthHandler) dummyPasswordHash() string {
+	h.dummyHashOnce.Do(func() {
+		password, _ := randomToken(32)
+		h.dummyHash, _ = h.hasher.Hash(password)
+	})
+	return h.dummyHash
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
 	var input models.UserInput
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -30,8 +86,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	if err := utils.Validate.Struct(input); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+	if !h.validateNewPassword(c, input, input.Password, input.Username, input.Email) {
 		return
 	}
 
@@ -48,11 +103,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	user := &models.User{
 		Username:  input.Username,
 		Email:     input.Email,
+		Role:      models.RoleAuthor,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := user.SetPassword(input.Password); err != nil {
+	if err := user.SetPassword(h.hasher, input.Password); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Password hashing failed")
 		return
 	}
@@ -62,26 +118,101 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := h.generateTokenPair(user.ID)
+	// The account works straight away; a failure to send is logged and the
+	// user can ask for another link.
+	if err := h.sendVerification(user.ID, user.Username, user.Email); err != nil {
+		c.Error(err)
+	}
+
+	h.respondWithSession(c, user)
+}
+
+// Login signs a user in by username or email address. Failed attempts
+// are counted against the account and the client address; see LoginLimits.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input models.LoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	user, err := h.getUserByIdentifier(input.Identifier)
+	if err != nil && err != sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	accountKey := unknownAccountThrottleKey(input.Identifier)
+	if user != nil {
+		accountKey = accountThrottleKey(user.ID)
+	}
+	attempt, ok := h.startLoginAttempt(c, accountKey, ipThrottleKey(c.ClientIP()))
+	if !ok {
+		return
+	}
+
+	// Unknown identifiers take as long as wrong passwords, so that timing
+	// does not tell which accounts exist.
+	if user == nil {
+		passhash.Verify(input.Password, h.dummyPasswordHash())
+	}
+	if user == nil || !user.CheckPassword(input.Password) {
+		attempt.fail()
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	if err := attempt.succeed(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Only now is the password at hand to upgrade an old hash with. The
+	// old hash still works, so failing to replace it is not fatal.
+	if h.hasher.NeedsRehash(user.PasswordHash) {
+		if err := h.rehashPassword(user, input.Password); err != nil {
+			c.Error(err)
+		}
+	}
+
+	// A forced logout only affects tokens that already exist.
+	if _, err := middleware.CheckAccount(h.db, user.ID, time.Now()); err != nil && err != middleware.ErrTokenRevoked {
+		middleware.AccountErrorResponse(c, err)
+		return
+	}
+
+	twoFactor, err := h.getTwoFactorState(user.ID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if twoFactor.enabled {
+		challengeToken, err := h.generateChallengeToken(user.ID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed")
+			return
+		}
+
+		utils.SuccessResponse(c, gin.H{
+			"twoFactorRequired": true,
+			"challengeToken":    challengeToken,
+			"expiresIn":         int(challengeTTL.Seconds()),
+		})
 		return
 	}
 
-	utils.SuccessResponse(c, gin.H{
-		"user":         user.Sanitize(),
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
-		"tokenType":    "Bearer",
-		"expiresIn":    3600,
-	})
+	h.respondWithSession(c, user)
 }
 
-func (h *AuthHandler) Login(c *gin.Context) {
+// RefreshToken trades a single-use refresh token for a new token pair.
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var input struct {
-		Username string `json:"username" validate:"required,username"`
-		Email    string `json:"email" validate:"required,email"`
-		Password string `json:"password" validate:"required"`
+		RefreshToken string `json:"refreshToken" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -89,28 +220,33 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.getUserByUsernameAndEmail(input.Username, input.Email)
-	if err == sql.ErrNoRows {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	rt, err := h.checkRefreshToken(input.RefreshToken)
+	if err != nil {
+		refreshErrorResponse(c, err)
 		return
-	} else if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+	}
+
+	// The role is read afresh so that role changes reach the access token
+	// at the next refresh. Forced logouts have already revoked the token.
+	role, err := middleware.CheckAccount(h.db, rt.userID, time.Now())
+	if err != nil && err != middleware.ErrTokenRevoked {
+		middleware.AccountErrorResponse(c, err)
 		return
 	}
 
-	if !user.CheckPassword(input.Password) {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	refreshToken, err := h.rotateRefreshToken(rt, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		refreshErrorResponse(c, err)
 		return
 	}
 
-	accessToken, refreshToken, err := h.generateTokenPair(user.ID)
+	accessToken, err := h.generateAccessToken(rt.userID, role, rt.sessionID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed")
 		return
 	}
 
 	utils.SuccessResponse(c, gin.H{
-		"user":         user.Sanitize(),
 		"accessToken":  accessToken,
 		"refreshToken": refreshToken,
 		"tokenType":    "Bearer",
@@ -118,7 +254,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
-func (h *AuthHandler) RefreshToken(c *gin.Context) {
+// Logout ends the session a refresh token belongs to. It succeeds for
+// tokens that are unknown or already revoked, so it can safely be retried.
+func (h *AuthHandler) Logout(c *gin.Context) {
 	var input struct {
 		RefreshToken string `json:"refreshToken" binding:"required"`
 	}
@@ -128,36 +266,57 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	token, err := jwt.Parse(input.RefreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	rt, err := h.getRefreshToken(input.RefreshToken)
+	if err == nil {
+		err = h.revokeSession(rt.userID, rt.familyID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to log out")
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+	utils.SuccessResponse(c, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll ends every session of the caller, including the one making
+// the request.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
 		return
 	}
 
-	userID := int64(claims["user_id"].(float64))
-	tokenType := claims["type"].(string)
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
 
-	if tokenType != "refresh" {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token type")
+	userID := c.GetInt64("user_id")
+	if _, err := revokeAllTokens(tx, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to log out")
 		return
 	}
+	h.forget(userID)
 
-	accessToken, refreshToken, err := h.generateTokenPair(userID)
+	utils.SuccessResponse(c, gin.H{"message": "Logged out of all sessions"})
+}
+
+// respondWithSession signs user in on the calling device.
+func (h *AuthHandler) respondWithSession(c *gin.Context, user *models.User) {
+	accessToken, refreshToken, err := h.generateTokenPair(user.ID, user.Role, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Token generation failed")
 		return
 	}
 
 	utils.SuccessResponse(c, gin.H{
+		"user":         user.Sanitize(),
 		"accessToken":  accessToken,
 		"refreshToken": refreshToken,
 		"tokenType":    "Bearer",
@@ -165,30 +324,64 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	})
 }
 
-func (h *AuthHandler) generateTokenPair(userID int64) (string, string, error) {
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"type":    "access",
-		"exp":     time.Now().Add(time.Hour).Unix(),
-	})
+func refreshErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case errRefreshTokenInvalid:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid refresh token")
+	case errRefreshTokenReused:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Refresh token reuse detected, please sign in again")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+	}
+}
 
-	accessTokenString, err := accessToken.SignedString([]byte(h.jwtSecret))
+// generateTokenPair starts a session and returns its first token pair.
+func (h *AuthHandler) generateTokenPair(userID int64, role, userAgent, ip string) (string, string, error) {
+	familyID, err := randomToken(16)
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"type":    "refresh",
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(),
-	})
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	sessionID, err := createSession(tx, userID, familyID, userAgent, ip)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := issueRefreshToken(tx, userID, familyID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
 
-	refreshTokenString, err := refreshToken.SignedString([]byte(h.jwtSecret))
+	accessToken, err := h.generateAccessToken(userID, role, sessionID)
 	if err != nil {
 		return "", "", err
 	}
 
-	return accessTokenString, refreshTokenString, nil
+	return accessToken, refreshToken, nil
+}
+
+// generateAccessToken signs an access token for a session. The session is
+// carried as "sid" so that revoking it takes the access token down too.
+func (h *AuthHandler) generateAccessToken(userID int64, role string, sessionID int64) (string, error) {
+	now := time.Now()
+	return h.keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"type":    "access",
+		"sid":     sessionID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(time.Hour).Unix(),
+	})
 }
 
 func (h *AuthHandler) checkUserExists(email, username string) (bool, error) {
@@ -200,13 +393,14 @@ func (h *AuthHandler) checkUserExists(email, username string) (bool, error) {
 
 func (h *AuthHandler) createUser(user *models.User) error {
 	query := `
-		INSERT INTO users (username, email, password_hash, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO users (username, email, password_hash, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 	result, err := h.db.Exec(query,
 		user.Username,
 		user.Email,
 		user.PasswordHash,
+		user.Role,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -223,23 +417,63 @@ func (h *AuthHandler) createUser(user *models.User) error {
 	return nil
 }
 
-func (h *AuthHandler) getUserByUsernameAndEmail(username, email string) (*models.User, error) {
+// rehashPassword replaces user's password hash with one made by h.hasher,
+// unless the password was changed in the meantime.
+func (h *AuthHandler) rehashPassword(user *models.User, password string) error {
+	oldHash := user.PasswordHash
+	if err := user.SetPassword(h.hasher, password); err != nil {
+		return err
+	}
+	_, err := h.db.Exec(
+		"UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?",
+		user.PasswordHash, user.ID, oldHash,
+	)
+	return err
+}
+
+// getUserByIdentifier finds the account whose username or email address is
+// identifier. Usernames cannot contain an @, so at most one matches.
+func (h *AuthHandler) getUserByIdentifier(identifier string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified_at
 		FROM users
-		WHERE username = ? email = ?
+		WHERE username = ? OR email = ?
 	`
-	err := h.db.QueryRow(query, username, email).Scan(
+	err := h.db.QueryRow(query, identifier, identifier).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.EmailVerifiedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 	return user, err
 }
+
+func (h *AuthHandler) getUserByID(id int64) (*models.User, error) {
+	user := &models.User{}
+	err := h.db.QueryRow(`
+		SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified_at
+		FROM users
+		WHERE id = ?
+	`, id).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.EmailVerifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -6,15 +6,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/signing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 
 	// For SQLite in-memory database testing
 	_ "github.com/mattn/go-sqlite3"
@@ -27,6 +31,60 @@ import (
 // Test files must end with _test.go
 // Test functions must have signature: func TestXxx(t *testing.T)
 
+// authTestSchema holds the tables the auth handler touches.
+const authTestSchema = `
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT UNIQUE NOT NULL,
+		email TEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'author',
+		suspended_until DATETIME,
+		banned_at DATETIME,
+		status_reason TEXT,
+		tokens_revoked_at DATETIME,
+		totp_secret TEXT,
+		totp_enabled_at DATETIME,
+		totp_last_step INTEGER,
+		email_verified_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE refresh_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		family_id TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		revoked_at DATETIME
+	);
+
+	CREATE TABLE sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		family_id TEXT NOT NULL UNIQUE,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_seen_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);
+
+	CREATE TABLE email_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		purpose TEXT NOT NULL,
+		email TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME
+	)
+`
+
 // ===== TEST SUITE PATTERN =====
 // Using testify/suite for organized, setup/teardown testing
 // Suite pattern is excellent for integration tests with shared setup
@@ -43,23 +101,15 @@ func (suite *AuthHandlerTestSuite) SetupSuite() {
 	// In memory DB is perfect for tests  fast, isolated, no cleanup needed
 	db, err := sql.Open("sqlite3", ":memory:")
 	suite.Require().NoError(err, "Failed to create test database")
+	// Every connection to :memory: opens a database of its own
+	db.SetMaxOpenConns(1)
 
 	// create users table schema
-	createTableQuery := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`
-	_, err = db.Exec(createTableQuery)
+	_, err = db.Exec(authTestSchema)
 	suite.Require().NoError(err, "Failed to create users table")
 
 	suite.db = db
-	suite.handler = NewAuthHandler(db, "test-secret-key")
+	suite.handler = NewAuthHandler(db, AuthOptions{Keys: signing.NewHMAC("test-secret-key"), Mailer: &recordingMailer{}})
 
 	// setup gin router in test mode
 	gin.SetMode(gin.TestMode)
@@ -85,7 +135,7 @@ func (suite *AuthHandlerTestSuite) TearDownSuite() {
 func (suite *AuthHandlerTestSuite) SetupTest() {
 	// Clean up database before each test for isolation
 	// This ensures each test starts with a clean state
-	_, err := suite.db.Exec("DELETE FROM users")
+	_, err := suite.db.Exec("DELETE FROM users; DELETE FROM refresh_tokens; DELETE FROM sessions; DELETE FROM email_tokens")
 	suite.Require().NoError(err, "Failed to clean test database")
 }
 
@@ -98,11 +148,12 @@ func (suite *AuthHandlerTestSuite) createTestUser(username, email, password stri
 	user := &models.User{
 		Username:  username,
 		Email:     email,
+		Role:      models.RoleAuthor,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	err := user.SetPassword(password)
+	err := user.SetPassword(suite.handler.hasher, password)
 	suite.Require().NoError(err, "Failed to set password")
 
 	err = suite.handler.createUser(user)
@@ -156,9 +207,9 @@ func (suite *AuthHandlerTestSuite) TestRegister_Success() {
 	// Test HTTP status code
 	suite.Equal(http.StatusOK, w.Code)
 
-	// Parse and verify response structure
+	// Parse and verify response structure; the payload is wrapped in data
 	var response map[string]interface{}
-	suite.parseJSONResponse(w, &response)
+	decodeData(suite.T(), w, &response)
 
 	// Verify response contains expected fields
 	suite.Contains(response, "user")
@@ -173,13 +224,13 @@ func (suite *AuthHandlerTestSuite) TestRegister_Success() {
 
 	// Verify user data
 	userData := response["user"].(map[string]interface{})
-	suite.Equal("testuser", userData["username"])
-	suite.Equal("test@example.com", userData["email"])
+	suite.Equal("testuser22", userData["username"])
+	suite.Equal("test22@example.com", userData["email"])
 	suite.NotContains(userData, "password") // Ensure password is not returned
 
 	// Verify user was actually created in database
 	var count int
-	err := suite.db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", "testuser").Scan(&count)
+	err := suite.db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", "testuser22").Scan(&count)
 	suite.NoError(err)
 	suite.Equal(1, count)
 }
@@ -253,7 +304,7 @@ func (suite *AuthHandlerTestSuite) TestRegister_DuplicateUser() {
 			requestBody := models.UserInput{
 				Username: tc.username,
 				Email:    tc.email,
-				Password: "password123",
+				Password: "StrongP@ss123",
 			}
 
 			w := suite.makeRequest("POST", "/auth/register", requestBody)
@@ -274,38 +325,44 @@ func (suite *AuthHandlerTestSuite) TestLogin_Success() {
 	testPassword := "password123"
 	user := suite.createTestUser("loginuser", "login@example.com", testPassword)
 
-	requestBody := map[string]string{
-		"username": user.Username,
-		"email":    user.Email,
-		"password": testPassword,
-	}
-
-	w := suite.makeRequest("POST", "/auth/login", requestBody)
-	suite.Equal(http.StatusOK, w.Code)
-
-	var response map[string]interface{}
-	suite.parseJSONResponse(w, &response)
-
-	// Verify response structure
-	suite.Contains(response, "user")
-	suite.Contains(response, "accessToken")
-	suite.Contains(response, "refreshToken")
+	// Either the username or the email address signs in
+	for _, identifier := range []string{user.Username, user.Email} {
+		suite.Run(identifier, func() {
+			requestBody := map[string]string{
+				"identifier": identifier,
+				"password":   testPassword,
+			}
 
-	// Verify tokens are valid JWT tokens
-	accessToken := response["accessToken"].(string)
-	suite.NotEmpty(accessToken)
+			w := suite.makeRequest("POST", "/auth/login", requestBody)
+			suite.Equal(http.StatusOK, w.Code)
 
-	// Parse JWT token to verify structure
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte("test-secret-key"), nil
-	})
-	suite.NoError(err)
-	suite.True(token.Valid)
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			suite.parseJSONResponse(w, &response)
 
-	// Verify token claims
-	claims := token.Claims.(jwt.MapClaims)
-	suite.Equal("access", claims["type"])
-	suite.Equal(float64(user.ID), claims["user_id"])
+			// Verify response structure
+			suite.Contains(response.Data, "user")
+			suite.Contains(response.Data, "accessToken")
+			suite.Contains(response.Data, "refreshToken")
+
+			// Verify tokens are valid JWT tokens
+			accessToken := response.Data["accessToken"].(string)
+			suite.NotEmpty(accessToken)
+
+			// Parse JWT token to verify structure
+			token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+				return []byte("test-secret-key"), nil
+			})
+			suite.NoError(err)
+			suite.True(token.Valid)
+
+			// Verify token claims
+			claims := token.Claims.(jwt.MapClaims)
+			suite.Equal("access", claims["type"])
+			suite.Equal(float64(user.ID), claims["user_id"])
+		})
+	}
 }
 
 func (suite *AuthHandlerTestSuite) TestLogin_InvalidCredentials() {
@@ -313,23 +370,21 @@ func (suite *AuthHandlerTestSuite) TestLogin_InvalidCredentials() {
 	user := suite.createTestUser("loginuser", "login@example.com", "password123")
 
 	testCases := []struct {
-		name     string
-		username string
-		email    string
-		password string
+		name       string
+		identifier string
+		password   string
 	}{
-		{"wrong_password", user.Username, user.Email, "wrongpassword"},
-		{"wrong_username", "wronguser", user.Email, "password123"},
-		{"wrong_email", user.Username, "wrong@example.com", "password123"},
-		{"user_not_exists", "nonexistent", "nonexistent@example.com", "password123"},
+		{"wrong_password_by_username", user.Username, "wrongpassword"},
+		{"wrong_password_by_email", user.Email, "wrongpassword"},
+		{"wrong_username", "wronguser", "password123"},
+		{"wrong_email", "wrong@example.com", "password123"},
 	}
 
 	for _, tc := range testCases {
 		suite.Run(tc.name, func() {
 			requestBody := map[string]string{
-				"username": tc.username,
-				"email":    tc.email,
-				"password": tc.password,
+				"identifier": tc.identifier,
+				"password":   tc.password,
 			}
 
 			w := suite.makeRequest("POST", "/auth/login", requestBody)
@@ -343,12 +398,43 @@ func (suite *AuthHandlerTestSuite) TestLogin_InvalidCredentials() {
 	}
 }
 
+func (suite *AuthHandlerTestSuite) TestLogin_RehashesLegacyPassword() {
+	user := suite.createTestUser("legacyuser", "legacy@example.com", "password123")
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	suite.Require().NoError(err)
+	_, err = suite.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", string(legacy), user.ID)
+	suite.Require().NoError(err)
+
+	// A failed login leaves the hash alone
+	w := suite.makeRequest("POST", "/auth/login", map[string]string{"identifier": "legacyuser", "password": "wrongpassword"})
+	suite.Equal(http.StatusUnauthorized, w.Code)
+	var hash string
+	suite.Require().NoError(suite.db.QueryRow("SELECT password_hash FROM users WHERE id = ?", user.ID).Scan(&hash))
+	suite.Equal(string(legacy), hash)
+
+	// A successful one upgrades it to Argon2id
+	w = suite.makeRequest("POST", "/auth/login", map[string]string{"identifier": "legacyuser", "password": "password123"})
+	suite.Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(suite.db.QueryRow("SELECT password_hash FROM users WHERE id = ?", user.ID).Scan(&hash))
+	suite.True(strings.HasPrefix(hash, "$argon2id$"), hash)
+	suite.False(suite.handler.hasher.NeedsRehash(hash))
+
+	// and the password still works
+	w = suite.makeRequest("POST", "/auth/login", map[string]string{"identifier": "legacy@example.com", "password": "password123"})
+	suite.Equal(http.StatusOK, w.Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestLogin_MissingIdentifier() {
+	w := suite.makeRequest("POST", "/auth/login", map[string]string{"password": "password123"})
+	suite.Equal(http.StatusBadRequest, w.Code)
+}
+
 // ===== REFRESH TOKEN TESTS =====
 
 func (suite *AuthHandlerTestSuite) TestRefreshToken_Success() {
 	// Create test user and generate initial tokens
 	user := suite.createTestUser("refreshuser", "refresh@example.com", "password123")
-	_, refreshToken, err := suite.handler.generateTokenPair(user.ID)
+	_, refreshToken, err := suite.handler.generateTokenPair(user.ID, user.Role, "test-agent", "127.0.0.1")
 	suite.NoError(err)
 
 	requestBody := map[string]string{
@@ -359,7 +445,7 @@ func (suite *AuthHandlerTestSuite) TestRefreshToken_Success() {
 	suite.Equal(http.StatusOK, w.Code)
 
 	var response map[string]interface{}
-	suite.parseJSONResponse(w, &response)
+	decodeData(suite.T(), w, &response)
 
 	suite.Contains(response, "accessToken")
 	suite.Contains(response, "refreshToken")
@@ -373,13 +459,15 @@ func (suite *AuthHandlerTestSuite) TestRefreshToken_Success() {
 
 func (suite *AuthHandlerTestSuite) TestRefreshToken_InvalidToken() {
 	testCases := []struct {
-		name  string
-		token string
+		name           string
+		token          string
+		expectedStatus int
 	}{
-		{"invalid_jwt", "invalid.jwt.token"},
-		{"empty_token", ""},
-		{"expired_token", suite.generateExpiredToken()},
-		{"access_token_instead_of_refresh", suite.generateAccessToken()},
+		{"invalid_jwt", "invalid.jwt.token", http.StatusUnauthorized},
+		// A missing token is a malformed request rather than a bad token
+		{"empty_token", "", http.StatusBadRequest},
+		{"expired_token", suite.generateExpiredToken(), http.StatusUnauthorized},
+		{"access_token_instead_of_refresh", suite.generateAccessToken(), http.StatusUnauthorized},
 	}
 
 	for _, tc := range testCases {
@@ -389,7 +477,7 @@ func (suite *AuthHandlerTestSuite) TestRefreshToken_InvalidToken() {
 			}
 
 			w := suite.makeRequest("POST", "/auth/refresh", requestBody)
-			suite.Equal(http.StatusUnauthorized, w.Code)
+			suite.Equal(tc.expectedStatus, w.Code)
 		})
 	}
 }
@@ -420,7 +508,7 @@ func (suite *AuthHandlerTestSuite) TestCheckUserExists() {
 func (suite *AuthHandlerTestSuite) TestGenerateTokenPair() {
 	userID := int64(123)
 
-	accessToken, refreshToken, err := suite.handler.generateTokenPair(userID)
+	accessToken, refreshToken, err := suite.handler.generateTokenPair(userID, models.RoleAuthor, "test-agent", "127.0.0.1")
 	suite.NoError(err)
 	suite.NotEmpty(accessToken)
 	suite.NotEmpty(refreshToken)
@@ -436,15 +524,19 @@ func (suite *AuthHandlerTestSuite) TestGenerateTokenPair() {
 	suite.Equal(float64(userID), claims["user_id"])
 	suite.Equal("access", claims["type"])
 
-	// Verify refresh token claims
-	token, err = jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte("test-secret-key"), nil
-	})
+	// The access token names the session it belongs to
+	var sessionID int64
+	err = suite.db.QueryRow("SELECT id FROM sessions WHERE user_id = ?", userID).Scan(&sessionID)
 	suite.NoError(err)
+	suite.Equal(float64(sessionID), claims["sid"])
 
-	claims = token.Claims.(jwt.MapClaims)
-	suite.Equal(float64(userID), claims["user_id"])
-	suite.Equal("refresh", claims["type"])
+	// Refresh tokens are opaque and only stored hashed
+	var storedUserID int64
+	err = suite.db.QueryRow(
+		"SELECT user_id FROM refresh_tokens WHERE token_hash = ?", hashRefreshToken(refreshToken),
+	).Scan(&storedUserID)
+	suite.NoError(err)
+	suite.Equal(userID, storedUserID)
 }
 
 // ===== HELPER METHODS FOR COMPLEX TEST SCENARIOS =====
@@ -476,19 +568,9 @@ func BenchmarkAuthHandler_Register(b *testing.B) {
 	db, _ := sql.Open("sqlite3", ":memory:")
 	defer db.Close()
 
-	createTableQuery := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`
-	db.Exec(createTableQuery)
-
-	handler := NewAuthHandler(db, "test-secret")
+	db.Exec(authTestSchema)
+
+	handler := NewAuthHandler(db, AuthOptions{Keys: signing.NewHMAC("test-secret"), Mailer: &recordingMailer{}})
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 	router.POST("/register", handler.Register)
@@ -505,7 +587,7 @@ func BenchmarkAuthHandler_Register(b *testing.B) {
 		requestBody := models.UserInput{
 			Username: "testuser",
 			Email:    "test@example.com",
-			Password: "password123",
+			Password: "StrongP@ss123",
 		}
 		bodyBytes, _ := json.Marshal(requestBody)
 		req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(bodyBytes))
@@ -547,23 +629,14 @@ func TestAuthFlow_Integration(t *testing.T) {
 
 	// Setup
 	db, err := sql.Open("sqlite3", ":memory:")
-	assert.NoError(t, err)
+	require.NoError(t, err)
 	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(authTestSchema)
+	require.NoError(t, err)
 
-	createTableQuery := `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE NOT NULL,
-			email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`
-	_, err = db.Exec(createTableQuery)
-	assert.NoError(t, err)
-
-	handler := NewAuthHandler(db, "integration-test-secret")
+	handler := NewAuthHandler(db, AuthOptions{Keys: signing.NewHMAC("integration-test-secret"), Mailer: &recordingMailer{}})
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
@@ -578,7 +651,7 @@ func TestAuthFlow_Integration(t *testing.T) {
 	registerBody := models.UserInput{
 		Username: "integrationuser",
 		Email:    "integration@example.com",
-		Password: "password123",
+		Password: "StrongP@ss123",
 	}
 
 	bodyBytes, _ := json.Marshal(registerBody)
@@ -587,16 +660,12 @@ func TestAuthFlow_Integration(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
-
-	var registerResponse map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &registerResponse)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
 	// Step 2: Login with registered credentials
 	loginBody := map[string]string{
-		"username": "integrationuser",
-		"email":    "integration@example.com",
-		"password": "password123",
+		"identifier": "integrationuser",
+		"password":   "StrongP@ss123",
 	}
 
 	bodyBytes, _ = json.Marshal(loginBody)
@@ -605,14 +674,17 @@ func TestAuthFlow_Integration(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
-	var loginResponse map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &loginResponse)
+	var loginResponse struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	decodeData(t, w, &loginResponse)
+	require.NotEmpty(t, loginResponse.RefreshToken)
 
 	// Step 3: Use refresh token to get new tokens
 	refreshBody := map[string]string{
-		"refreshToken": loginResponse["refreshToken"].(string),
+		"refreshToken": loginResponse.RefreshToken,
 	}
 
 	bodyBytes, _ = json.Marshal(refreshBody)
@@ -621,11 +693,11 @@ func TestAuthFlow_Integration(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
 	// Verify complete flow worked
 	var refreshResponse map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &refreshResponse)
+	decodeData(t, w, &refreshResponse)
 	assert.Contains(t, refreshResponse, "accessToken")
 	assert.Contains(t, refreshResponse, "refreshToken")
 }
@@ -643,10 +715,11 @@ func TestNewAuthHandler(t *testing.T) {
 	db, _ := sql.Open("sqlite3", ":memory:")
 	defer db.Close()
 
-	handler := NewAuthHandler(db, "test-secret")
+	keys := signing.NewHMAC("test-secret")
+	handler := NewAuthHandler(db, AuthOptions{Keys: keys})
 
 	// Use testify/assert for clean assertions
 	assert.NotNil(t, handler)
 	assert.Equal(t, db, handler.db)
-	assert.Equal(t, "test-secret", handler.jwtSecret)
+	assert.Equal(t, keys, handler.keys)
 }
@@ -2,22 +2,108 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
 	"github.com/prem0x01/Blogy/utils"
 )
 
+// CommentOptions controls the shape of comment listings.
+type CommentOptions struct {
+	// TreeDepth is the maximum number of reply levels embedded under each
+	// listed comment. Clients may ask for fewer with ?depth=.
+	TreeDepth int
+	// RepliesPerBranch is how many replies are embedded per comment; the
+	// rest are paged through with ?parent_id=.
+	RepliesPerBranch int
+	// EditWindow is how long after posting its author may edit a comment.
+	EditWindow time.Duration
+	// DefaultMode is the moderation mode for posts that do not set one.
+	DefaultMode string
+	// Spam scores new and edited comments when set. Comments scoring
+	// SpamThreshold or more are held for moderation.
+	Spam          SpamChecker
+	SpamThreshold float64
+}
+
+// SpamChecker scores content from 0 (clean) to 1 (spam). spam.Classifier is
+// the built-in implementation.
+type SpamChecker interface {
+	Check(userID int64, content string) (float64, error)
+	Record(userID int64, content string) error
+	Learn(commentID int64, content string, isSpam bool) error
+}
+
+var errCommentsClosed = errors.New("comments are closed on this post")
+
 type CommentHandler struct {
-	db *sql.DB
+	db   *sql.DB
+	opts CommentOptions
 }
 
-func NewCommentHandler(db *sql.DB) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(db *sql.DB, opts CommentOptions) *CommentHandler {
+	return &CommentHandler{db: db, opts: opts}
+}
+
+// commentColumns selects a comment aliased c joined with its author u, in
+// the order scanComment expects.
+const commentColumns = `
+	c.id, c.post_id, c.user_id, c.parent_id, c.content, c.status, c.deleted_at IS NOT NULL,
+	c.created_at, c.updated_at, u.username, u.email`
+
+// visibleCommentFilter limits comments aliased c to approved ones and the
+// viewer's own. It takes the viewer's user ID.
+const visibleCommentFilter = "(c.status = 'approved' OR c.user_id = ?)"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
+// scanComment scans the columns in commentColumns, followed by any extra
+// columns the query selects into extra.
+func scanComment(row rowScanner, extra ...interface{}) (*models.Comment, error) {
+	comment := &models.Comment{Author: &models.User{}}
+	var parentID sql.NullInt64
+	var updatedAt sql.NullTime
+	var deleted bool
+	dest := []interface{}{
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&parentID,
+		&comment.Content,
+		&comment.Status,
+		&deleted,
+		&comment.CreatedAt,
+		&updatedAt,
+		&comment.Author.Username,
+		&comment.Author.Email,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	if updatedAt.Valid {
+		comment.UpdatedAt = &updatedAt.Time
+		comment.Edited = true
+	}
+	if deleted {
+		comment.MarkDeleted()
+	}
+	return comment, nil
+}
+
+// GetComments lists a post's top-level comments, or with ?parent_id= the
+// replies to one comment, each embedding the first replies of its branch.
 func (h *CommentHandler) GetComments(c *gin.Context) {
 	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -25,16 +111,69 @@ func (h *CommentHandler) GetComments(c *gin.Context) {
 		return
 	}
 
+	viewerID := c.GetInt64("user_id")
+
+	visible, err := postVisibleTo(h.db, postID, actorFrom(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	} else if !visible {
+		utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	var parentID *int64
+	if value := c.Query("parent_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid parent comment ID")
+			return
+		}
+
+		parent, err := h.getCommentByID(id)
+		if err == sql.ErrNoRows || (err == nil && !commentVisibleTo(parent, postID, viewerID)) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Comment not found")
+			return
+		} else if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		parentID = &id
+	}
+
+	depth := h.opts.TreeDepth
+	if value := c.Query("depth"); value != "" {
+		requested, err := strconv.Atoi(value)
+		if err != nil || requested < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid depth")
+			return
+		}
+		if requested < depth {
+			depth = requested
+		}
+	}
+
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset := (page - 1) * pageSize
 
-	comments, total, err := h.getComments(postID, pageSize, offset)
+	comments, total, err := h.getComments(postID, parentID, viewerID, pageSize, offset)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comments")
 		return
 	}
 
+	all, err := h.loadReplies(comments, depth, viewerID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+
+	if err := loadCommentLikes(h.db, all, viewerID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch likes")
+		return
+	}
+
 	utils.PaginatedSuccessResponse(c, comments, total, page, pageSize)
 }
 
@@ -45,6 +184,11 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
 	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
@@ -53,32 +197,90 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 
 	userID := c.GetInt64("user_id")
 
+	visible, err := postVisibleTo(h.db, postID, actorFrom(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	} else if !visible {
+		utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	if input.ParentID != nil {
+		parent, err := h.getCommentByID(*input.ParentID)
+		if err == sql.ErrNoRows || (err == nil && !commentVisibleTo(parent, postID, userID)) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Parent comment not found")
+			return
+		} else if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if parent.Deleted {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Cannot reply to a deleted comment")
+			return
+		}
+		if parent.Status != models.CommentStatusApproved {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Cannot reply to a comment awaiting moderation")
+			return
+		}
+	}
+
+	status, err := h.newCommentStatus(postID, userID)
+	if err == errCommentsClosed {
+		utils.ErrorResponse(c, http.StatusForbidden, "Comments are closed on this post")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
 	comment := &models.Comment{
-		PostID:  postID,
-		UserID:  userID,
-		Content: input.Content,
+		PostID:   postID,
+		UserID:   userID,
+		ParentID: input.ParentID,
+		Content:  input.Content,
+		Status:   status,
 	}
 
-	if err := h.createComment(comment); err != nil {
+	spamScore, err := h.screenComment(comment)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check comment")
+		return
+	}
+
+	if err := h.createComment(comment, spamScore); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create comment")
 		return
 	}
+	h.recordComment(c, comment)
 
 	utils.SuccessResponse(c, comment)
 }
 
-func (h *CommentHandler) DeleteComment(c *gin.Context) {
+// UpdateComment lets the author edit a comment within the edit window,
+// keeping the old content in its history.
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
 	commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment ID")
 		return
 	}
 
+	var input models.CommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
 	userID := c.GetInt64("user_id")
 
-	// Check ownership
 	comment, err := h.getCommentByID(commentID)
-	if err == sql.ErrNoRows {
+	if err == sql.ErrNoRows || (err == nil && comment.Deleted) {
 		utils.ErrorResponse(c, http.StatusNotFound, "Comment not found")
 		return
 	} else if err != nil {
@@ -87,6 +289,102 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	}
 
 	if comment.UserID != userID {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not authorized to edit this comment")
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > h.opts.EditWindow {
+		utils.ErrorResponse(c, http.StatusForbidden, "The edit window for this comment has passed")
+		return
+	}
+
+	if input.Content != comment.Content {
+		status, err := h.editedCommentStatus(comment)
+		if err == errCommentsClosed {
+			utils.ErrorResponse(c, http.StatusForbidden, "Comments are closed on this post")
+			return
+		} else if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		comment.Content = input.Content
+		comment.Status = status
+		spamScore, err := h.screenComment(comment)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check comment")
+			return
+		}
+
+		if err := h.updateComment(comment, spamScore); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update comment")
+			return
+		}
+		h.recordComment(c, comment)
+
+		if comment, err = h.getCommentByID(commentID); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comment")
+			return
+		}
+	}
+
+	utils.SuccessResponse(c, comment)
+}
+
+// GetCommentHistory lists the earlier versions of a comment, oldest first.
+// It is open to the comment's author and to whoever may moderate the post.
+func (h *CommentHandler) GetCommentHistory(c *gin.Context) {
+	commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	var authorID, postOwnerID int64
+	err = h.db.QueryRow(`
+		SELECT c.user_id, p.user_id
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND c.deleted_at IS NULL
+	`, commentID).Scan(&authorID, &postOwnerID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "Comment not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !policy.CanViewCommentHistory(actorFrom(c), authorID, postOwnerID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not authorized to view this comment's history")
+		return
+	}
+
+	revisions, err := h.getCommentRevisions(commentID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comment history")
+		return
+	}
+
+	utils.SuccessResponse(c, revisions)
+}
+
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	comment, err := h.getCommentByID(commentID)
+	if err == sql.ErrNoRows || (err == nil && comment.Deleted) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Comment not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if !policy.CanDeleteComment(actorFrom(c), comment.UserID) {
 		utils.ErrorResponse(c, http.StatusForbidden, "Not authorized to delete this comment")
 		return
 	}
@@ -99,53 +397,239 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	utils.SuccessResponse(c, gin.H{"message": "Comment deleted successfully"})
 }
 
+// commentVisibleTo reports whether comment belongs to postID and may be
+// seen by viewerID, following visibleCommentFilter.
+func commentVisibleTo(comment *models.Comment, postID, viewerID int64) bool {
+	return comment.PostID == postID &&
+		(comment.Status == models.CommentStatusApproved || comment.UserID == viewerID)
+}
+
+// newCommentStatus applies the post's moderation mode to a new comment.
+func (h *CommentHandler) newCommentStatus(postID, userID int64) (string, error) {
+	ownerID, mode, err := h.commentMode(postID)
+	if err != nil {
+		return "", err
+	}
+	if ownerID == userID {
+		return models.CommentStatusApproved, nil
+	}
+
+	switch mode {
+	case models.CommentModeHoldAll:
+		return models.CommentStatusPending, nil
+	case models.CommentModeHoldFirstTime:
+		var approvedBefore bool
+		err := h.db.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM comments WHERE user_id = ? AND status = 'approved')",
+			userID,
+		).Scan(&approvedBefore)
+		if err != nil {
+			return "", err
+		}
+		if !approvedBefore {
+			return models.CommentStatusPending, nil
+		}
+	}
+	return models.CommentStatusApproved, nil
+}
+
+// editedCommentStatus applies the post's moderation mode to an edit, so an
+// approved comment cannot be turned into anything afterwards.
+func (h *CommentHandler) editedCommentStatus(comment *models.Comment) (string, error) {
+	ownerID, mode, err := h.commentMode(comment.PostID)
+	if err != nil {
+		return "", err
+	}
+	if ownerID != comment.UserID &&
+		(mode == models.CommentModeHoldAll || mode == models.CommentModeHoldFirstTime) {
+		return models.CommentStatusPending, nil
+	}
+	return comment.Status, nil
+}
+
+// commentMode returns the owner of a post and the moderation mode that
+// applies to it, or errCommentsClosed when no comments may be written.
+func (h *CommentHandler) commentMode(postID int64) (int64, string, error) {
+	var ownerID int64
+	var mode string
+	var locked bool
+	err := h.db.QueryRow(`
+		SELECT user_id, COALESCE(comment_mode, ''), comments_locked
+		FROM posts
+		WHERE id = ?
+	`, postID).Scan(&ownerID, &mode, &locked)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if mode == "" {
+		mode = h.opts.DefaultMode
+	}
+	if locked || mode == models.CommentModeClosed {
+		return 0, "", errCommentsClosed
+	}
+	return ownerID, mode, nil
+}
+
+// screenComment holds comment for moderation when its spam score reaches
+// the threshold, and returns the score.
+func (h *CommentHandler) screenComment(comment *models.Comment) (*float64, error) {
+	if h.opts.Spam == nil {
+		return nil, nil
+	}
+
+	score, err := h.opts.Spam.Check(comment.UserID, comment.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if score >= h.opts.SpamThreshold && comment.Status == models.CommentStatusApproved {
+		comment.Status = models.CommentStatusPending
+	}
+	return &score, nil
+}
+
+// recordComment tells the spam checker about a stored comment. The comment
+// is saved either way, so a failure is logged but not reported.
+func (h *CommentHandler) recordComment(c *gin.Context, comment *models.Comment) {
+	if h.opts.Spam == nil {
+		return
+	}
+	if err := h.opts.Spam.Record(comment.UserID, comment.Content); err != nil {
+		c.Error(err)
+	}
+}
+
 // Database helper methods
-func (h *CommentHandler) getComments(postID int64, limit, offset int) ([]*models.Comment, int64, error) {
+func (h *CommentHandler) getComments(postID int64, parentID *int64, viewerID int64, limit, offset int) ([]*models.Comment, int64, error) {
+	where := "c.post_id = ? AND c.parent_id IS NULL AND " + visibleCommentFilter
+	order := "c.created_at DESC, c.id DESC"
+	args := []interface{}{postID, viewerID}
+	if parentID != nil {
+		where = "c.post_id = ? AND c.parent_id = ? AND " + visibleCommentFilter
+		order = "c.created_at, c.id"
+		args = []interface{}{postID, *parentID, viewerID}
+	}
+
 	var total int64
-	if err := h.db.QueryRow("SELECT COUNT(*) FROM comments WHERE post_id = ?", postID).Scan(&total); err != nil {
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM comments c WHERE "+where, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	rows, err := h.db.Query(`
-		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at,
-			   u.username, u.email
+		SELECT `+commentColumns+`
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = ?
-		ORDER BY c.created_at DESC
+		WHERE `+where+`
+		ORDER BY `+order+`
 		LIMIT ? OFFSET ?
-	`, postID, limit, offset)
+	`, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var comments []*models.Comment
+	comments := []*models.Comment{}
 	for rows.Next() {
-		comment := &models.Comment{Author: &models.User{}}
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.Author.Username,
-			&comment.Author.Email,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, 0, err
 		}
 		comments = append(comments, comment)
 	}
 
-	return comments, total, nil
+	return comments, total, rows.Err()
 }
 
-func (h *CommentHandler) createComment(comment *models.Comment) error {
+// loadReplies embeds replies depth levels deep, a level at a time, and
+// returns every comment in the tree.
+func (h *CommentHandler) loadReplies(roots []*models.Comment, depth int, viewerID int64) ([]*models.Comment, error) {
+	all := append([]*models.Comment(nil), roots...)
+
+	level := roots
+	for d := 0; len(level) > 0; d++ {
+		byID := make(map[int64]*models.Comment, len(level))
+		args := make([]interface{}, 0, len(level)+2)
+		for _, comment := range level {
+			byID[comment.ID] = comment
+			args = append(args, comment.ID)
+		}
+		args = append(args, viewerID)
+
+		if err := h.loadReplyCounts(byID, args); err != nil {
+			return nil, err
+		}
+		if d == depth {
+			break
+		}
+
+		rows, err := h.db.Query(`
+			SELECT `+commentColumns+`
+			FROM (
+				SELECT *, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at, id) AS position
+				FROM comments c
+				WHERE parent_id IN (`+placeholders(len(level))+`) AND `+visibleCommentFilter+`
+			) c
+			JOIN users u ON c.user_id = u.id
+			WHERE c.position <= ?
+			ORDER BY c.created_at, c.id
+		`, append(args, h.opts.RepliesPerBranch)...)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []*models.Comment
+		for rows.Next() {
+			reply, err := scanComment(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			parent := byID[*reply.ParentID]
+			parent.Replies = append(parent.Replies, reply)
+			next = append(next, reply)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		all = append(all, next...)
+		level = next
+	}
+
+	return all, nil
+}
+
+// loadReplyCounts counts the replies to each comment in byID that the
+// viewer can see. args holds the comment IDs followed by the viewer's ID.
+func (h *CommentHandler) loadReplyCounts(byID map[int64]*models.Comment, args []interface{}) error {
+	rows, err := h.db.Query(`
+		SELECT parent_id, COUNT(*)
+		FROM comments c
+		WHERE parent_id IN (`+placeholders(len(byID))+`) AND `+visibleCommentFilter+`
+		GROUP BY parent_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentID, count int64
+		if err := rows.Scan(&parentID, &count); err != nil {
+			return err
+		}
+		byID[parentID].ReplyCount = count
+	}
+	return rows.Err()
+}
+
+func (h *CommentHandler) createComment(comment *models.Comment, spamScore *float64) error {
 	result, err := h.db.Exec(`
-		INSERT INTO comments (post_id, user_id, content, created_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
-	`, comment.PostID, comment.UserID, comment.Content)
+		INSERT INTO comments (post_id, user_id, parent_id, content, status, spam_score, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, comment.PostID, comment.UserID, comment.ParentID, comment.Content, comment.Status, spamScore)
 	if err != nil {
 		return err
 	}
@@ -159,23 +643,123 @@ func (h *CommentHandler) createComment(comment *models.Comment) error {
 	return nil
 }
 
-func (h *CommentHandler) getCommentByID(id int64) (*models.Comment, error) {
-	comment := &models.Comment{}
-	err := h.db.QueryRow(`
-		SELECT id, post_id, user_id, content, created_at
+// updateComment saves the comment's stored content to its history and
+// replaces it with the content, status and spam score of comment.
+func (h *CommentHandler) updateComment(comment *models.Comment, spamScore *float64) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO comment_revisions (comment_id, content, created_at)
+		SELECT id, content, COALESCE(updated_at, created_at)
 		FROM comments
 		WHERE id = ?
-	`, id).Scan(
-		&comment.ID,
-		&comment.PostID,
-		&comment.UserID,
-		&comment.Content,
-		&comment.CreatedAt,
-	)
-	return comment, err
+	`, comment.ID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE comments
+		SET content = ?, status = ?, spam_score = COALESCE(?, spam_score), updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, comment.Content, comment.Status, spamScore, comment.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+func (h *CommentHandler) getCommentRevisions(commentID int64) ([]*models.CommentRevision, error) {
+	rows, err := h.db.Query(`
+		SELECT id, comment_id, content, created_at
+		FROM comment_revisions
+		WHERE comment_id = ?
+		ORDER BY id
+	`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.CommentRevision{}
+	for rows.Next() {
+		rev := &models.CommentRevision{}
+		if err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Content, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (h *CommentHandler) getCommentByID(id int64) (*models.Comment, error) {
+	return scanComment(h.db.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.id = ?
+	`, id))
+}
+
+// deleteComment removes a comment, or blanks it out while it has replies.
 func (h *CommentHandler) deleteComment(id int64) error {
-	_, err := h.db.Exec("DELETE FROM comments WHERE id = ?", id)
-	return err
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasReplies bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM comments WHERE parent_id = ?)", id).Scan(&hasReplies); err != nil {
+		return err
+	}
+
+	if hasReplies {
+		if _, err := tx.Exec(`
+			UPDATE comments SET content = '', deleted_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM comment_revisions WHERE comment_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM comment_likes WHERE comment_id = ?", id); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	for {
+		var parentID sql.NullInt64
+		if err := tx.QueryRow("SELECT parent_id FROM comments WHERE id = ?", id).Scan(&parentID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM comments WHERE id = ?", id); err != nil {
+			return err
+		}
+		if !parentID.Valid {
+			break
+		}
+
+		var orphaned bool
+		err := tx.QueryRow(`
+			SELECT deleted_at IS NOT NULL
+			   AND NOT EXISTS(SELECT 1 FROM comments WHERE parent_id = p.id)
+			FROM comments p
+			WHERE p.id = ?
+		`, parentID.Int64).Scan(&orphaned)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			break
+		}
+		id = parentID.Int64
+	}
+
+	return tx.Commit()
 }
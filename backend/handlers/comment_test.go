@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type commentPage struct {
+	Items      []*models.Comment `json:"items"`
+	TotalItems int64             `json:"total_items"`
+}
+
+func TestThreadedComments(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	readerID := insertTestUser(t, db, "reader")
+	insertTestPost(t, db, authorID, "thread", "Thread", "Some content here.")
+
+	gin.SetMode(gin.TestMode)
+	comments := NewCommentHandler(db.DB, CommentOptions{TreeDepth: 2, RepliesPerBranch: 2})
+
+	router := gin.New()
+	router.GET("/posts/:id/comments", comments.GetComments)
+	reader := router.Group("/reader", withUser(readerID))
+	reader.POST("/posts/:id/comments", comments.CreateComment)
+	reader.DELETE("/comments/:id", comments.DeleteComment)
+
+	comment := func(parentID int64, content string) int64 {
+		t.Helper()
+		input := gin.H{"content": content}
+		if parentID != 0 {
+			input["parent_id"] = parentID
+		}
+		w := doJSON(router, "POST", "/reader/posts/1/comments", input)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var created models.Comment
+		decodeData(t, w, &created)
+		return created.ID
+	}
+
+	root := comment(0, "root")
+	first := comment(root, "first reply")
+	comment(root, "second reply")
+	comment(root, "third reply")
+	nested := comment(first, "nested")
+	deepest := comment(nested, "deepest")
+
+	var page commentPage
+	w := doJSON(router, "GET", "/posts/1/comments", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	require.Len(t, page.Items, 1)
+
+	tree := page.Items[0]
+	assert.Equal(t, int64(3), tree.ReplyCount)
+	require.Len(t, tree.Replies, 2)
+	assert.Equal(t, "first reply", tree.Replies[0].Content)
+	assert.Equal(t, "second reply", tree.Replies[1].Content)
+
+	// The depth limit stops at the nested reply but still reports its count.
+	require.Len(t, tree.Replies[0].Replies, 1)
+	assert.Equal(t, int64(1), tree.Replies[0].Replies[0].ReplyCount)
+	assert.Empty(t, tree.Replies[0].Replies[0].Replies)
+
+	w = doJSON(router, "GET", fmt.Sprintf("/posts/1/comments?parent_id=%d&limit=2&page=2", root), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	assert.Equal(t, int64(3), page.TotalItems)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "third reply", page.Items[0].Content)
+
+	w = doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": "x", "parent_id": 999})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": strings.Repeat("x", 1001)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A deleted comment with replies becomes a placeholder.
+	require.Equal(t, http.StatusOK, doJSON(router, "DELETE", fmt.Sprintf("/reader/comments/%d", first), nil).Code)
+	var after commentPage
+	w = doJSON(router, "GET", "/posts/1/comments", nil)
+	decodeData(t, w, &after)
+	placeholder := after.Items[0].Replies[0]
+	assert.True(t, placeholder.Deleted)
+	assert.Equal(t, models.DeletedCommentContent, placeholder.Content)
+	assert.Nil(t, placeholder.Author)
+	assert.Len(t, placeholder.Replies, 1)
+
+	w = doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": "x", "parent_id": first})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "DELETE", fmt.Sprintf("/reader/comments/%d", first), nil).Code)
+
+	// Once its last reply is gone the placeholder goes too.
+	require.Equal(t, http.StatusOK, doJSON(router, "DELETE", fmt.Sprintf("/reader/comments/%d", deepest), nil).Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "DELETE", fmt.Sprintf("/reader/comments/%d", nested), nil).Code)
+
+	var remaining int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM comments").Scan(&remaining))
+	assert.Equal(t, 3, remaining)
+}
+
+func TestUpdateComment(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	readerID := insertTestUser(t, db, "reader")
+	otherID := insertTestUser(t, db, "other")
+	insertTestPost(t, db, authorID, "edits", "Edits", "Some content here.")
+
+	gin.SetMode(gin.TestMode)
+	comments := NewCommentHandler(db.DB, CommentOptions{TreeDepth: 1, RepliesPerBranch: 5, EditWindow: time.Hour})
+
+	router := gin.New()
+	for name, id := range map[string]int64{"author": authorID, "reader": readerID, "other": otherID} {
+		group := router.Group("/"+name, withUser(id))
+		group.POST("/posts/:id/comments", comments.CreateComment)
+		group.PUT("/comments/:id", comments.UpdateComment)
+		group.GET("/comments/:id/history", comments.GetCommentHistory)
+	}
+
+	w := doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": "frist"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "PUT", "/other/comments/1", gin.H{"content": "hijacked"}).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "PUT", "/reader/comments/1", gin.H{"content": ""}).Code)
+
+	var comment models.Comment
+	w = doJSON(router, "PUT", "/reader/comments/1", gin.H{"content": "first"})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &comment)
+	assert.Equal(t, "first", comment.Content)
+	assert.True(t, comment.Edited)
+	assert.NotNil(t, comment.UpdatedAt)
+
+	require.Equal(t, http.StatusOK, doJSON(router, "PUT", "/reader/comments/1", gin.H{"content": "first!"}).Code)
+
+	// The post owner can see what was changed; other readers cannot.
+	var history []models.CommentRevision
+	w = doJSON(router, "GET", "/author/comments/1/history", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &history)
+	require.Len(t, history, 2)
+	assert.Equal(t, "frist", history[0].Content)
+	assert.Equal(t, "first", history[1].Content)
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "GET", "/other/comments/1/history", nil).Code)
+
+	_, err := db.Exec("UPDATE comments SET created_at = ? WHERE id = 1", time.Now().UTC().Add(-2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "PUT", "/reader/comments/1", gin.H{"content": "too late"}).Code)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/mail"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+const emailTokenTTL = 24 * time.Hour
+
+// Purposes of an email token.
+const (
+	emailVerify = "verify"
+	emailChange = "change"
+)
+
+// VerifyEmail follows a link from sendVerification or ChangeEmail.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var tokenID, userID int64
+	var purpose, email, username, oldEmail string
+	err = tx.QueryRow(`
+		SELECT t.id, t.user_id, t.purpose, t.email, u.username, u.email
+		FROM email_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND t.used_at IS NULL AND t.expires_at > ?
+	`, hashRefreshToken(token), now).Scan(&tokenID, &userID, &purpose, &email, &username, &oldEmail)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE email_tokens SET used_at = ? WHERE id = ?", now, tokenID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email address")
+		return
+	}
+
+	switch purpose {
+	case emailVerify:
+		// A link for an address the account has since moved away from is
+		// no good.
+		if email != oldEmail {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired verification link")
+			return
+		}
+		if _, err := tx.Exec(
+			"UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?",
+			now, userID,
+		); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email address")
+			return
+		}
+
+	case emailChange:
+		var taken bool
+		if err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND id != ?)", email, userID,
+		).Scan(&taken); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if taken {
+			utils.ErrorResponse(c, http.StatusConflict, "Email address is already in use")
+			return
+		}
+
+		if _, err := tx.Exec(
+			"UPDATE users SET email = ?, email_verified_at = ?, updated_at = ? WHERE id = ?",
+			email, now, now, userID,
+		); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change email address")
+			return
+		}
+
+		// Links sent to any other address are void now.
+		if _, err := tx.Exec(
+			"UPDATE email_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+			now, userID,
+		); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change email address")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify email address")
+		return
+	}
+	h.forget(userID)
+
+	if purpose == emailChange {
+		if err := h.mailer.Send(emailChangedMessage(oldEmail, username, email)); err != nil {
+			c.Error(err)
+		}
+		utils.SuccessResponse(c, gin.H{"message": "Email address changed", "email": email})
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Email address verified"})
+}
+
+// ResendVerification sends the caller another verification link.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user, err := h.getUserByID(c.GetInt64("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		utils.ErrorResponse(c, http.StatusConflict, "Email address is already verified")
+		return
+	}
+
+	if err := h.sendVerification(user.ID, user.Username, user.Email); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Verification email sent"})
+}
+
+// ChangeEmail mails a confirmation link to a new address; nothing changes
+// until it is followed.
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+		return
+	}
+
+	var input models.ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	user, err := h.getUserByID(c.GetInt64("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	attempt, ok := h.startLoginAttempt(c, accountThrottleKey(user.ID), ipThrottleKey(c.ClientIP()))
+	if !ok {
+		return
+	}
+	if !user.CheckPassword(input.Password) {
+		attempt.fail()
+		utils.ErrorResponse(c, http.StatusBadRequest, "Incorrect password")
+		return
+	}
+	if err := attempt.succeed(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if input.Email == user.Email {
+		utils.ErrorResponse(c, http.StatusBadRequest, "That is already your email address")
+		return
+	}
+
+	var taken bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", input.Email).Scan(&taken); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if taken {
+		utils.ErrorResponse(c, http.StatusConflict, "Email address is already in use")
+		return
+	}
+
+	token, err := issueEmailToken(h.db, user.ID, emailChange, input.Email)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if err := h.mailer.Send(emailChangeMessage(input.Email, user.Username, h.appURL, token)); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to send confirmation email")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Follow the link sent to your new address to confirm the change"})
+}
+
+func (h *AuthHandler) sendVerification(userID int64, username, email string) error {
+	token, err := issueEmailToken(h.db, userID, emailVerify, email)
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(verificationMessage(email, username, h.appURL, token))
+}
+
+// issueEmailToken stores a new token confirming email for purpose and
+// returns it. Only its hash is kept.
+func issueEmailToken(exec execer, userID int64, purpose, email string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = exec.Exec(`
+		INSERT INTO email_tokens (user_id, purpose, email, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, purpose, email, hashRefreshToken(token), now, now.Add(emailTokenTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func verifyEmailLink(appURL, token string) string {
+	return appURL + "/verify-email?token=" + url.QueryEscape(token)
+}
+
+func verificationMessage(email, username, appURL, token string) mail.Message {
+	return mail.Message{
+		To:      email,
+		Subject: "Confirm your email address for Blogy",
+		Body: fmt.Sprintf(`Hi %s,
+
+Welcome to Blogy! Please confirm this is your email address by opening
+this link within the next 24 hours:
+
+%s
+
+If you did not sign up, you can ignore this email.
+`, username, verifyEmailLink(appURL, token)),
+	}
+}
+
+func emailChangeMessage(email, username, appURL, token string) mail.Message {
+	return mail.Message{
+		To:      email,
+		Subject: "Confirm your new email address for Blogy",
+		Body: fmt.Sprintf(`Hi %s,
+
+To move your Blogy account to this address, open this link within the
+next 24 hours:
+
+%s
+
+If you did not ask for this, you can ignore this email.
+`, username, verifyEmailLink(appURL, token)),
+	}
+}
+
+func emailChangedMessage(email, username, newEmail string) mail.Message {
+	return mail.Message{
+		To:      email,
+		Subject: "Your Blogy email address was changed",
+		Body: fmt.Sprintf(`Hi %s,
+
+The email address of your Blogy account was changed to %s, and emails
+will go there from now on.
+
+If you did not do this, reset your password straight away and get in
+touch with us.
+`, username, newEmail),
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verifyLink = regexp.MustCompile(`https://blogy\.test/verify-email\?token=(\S+)`)
+
+func TestEmailVerification(t *testing.T) {
+	db := newTestDatabase(t)
+	insertTestUser(t, db, "bob")
+
+	gin.SetMode(gin.TestMode)
+	keys := signing.NewHMAC("test-secret")
+	authenticator := middleware.NewAuthenticator(keys, db.DB, time.Minute)
+	mailer := &recordingMailer{}
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:     keys,
+		Sessions: authenticator,
+		Mailer:   mailer,
+		AppURL:   "https://blogy.test",
+	})
+	router := gin.New()
+	router.POST("/register", auth.Register)
+	router.GET("/verify-email", auth.VerifyEmail)
+	protected := router.Group("", middleware.AuthMiddleware(authenticator))
+	protected.POST("/posts", middleware.RequireVerifiedEmail(true), func(c *gin.Context) {
+		utils.SuccessResponse(c, gin.H{})
+	})
+	protected.POST("/me/email", auth.ChangeEmail)
+	protected.POST("/me/email/verification", auth.ResendVerification)
+
+	lastLink := func(to string) string {
+		t.Helper()
+		sent := mailer.messages()
+		require.NotEmpty(t, sent)
+		msg := sent[len(sent)-1]
+		require.Equal(t, to, msg.To)
+		match := verifyLink.FindStringSubmatch(msg.Body)
+		require.NotNil(t, match, msg.Body)
+		return "/verify-email?token=" + match[1]
+	}
+
+	w := doJSON(router, "POST", "/register", gin.H{
+		"username": "alice", "email": "alice@example.com", "password": "Passw0rd!",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var registered struct {
+		User        map[string]interface{} `json:"user"`
+		AccessToken string                 `json:"accessToken"`
+	}
+	decodeData(t, w, &registered)
+	assert.Equal(t, false, registered.User["email_verified"])
+	token := registered.AccessToken
+	firstLink := lastLink("alice@example.com")
+
+	// Unverified users are kept from posting when the policy says so.
+	assert.Equal(t, http.StatusForbidden, doJSONWithToken(router, token, "POST", "/posts", nil).Code)
+
+	require.Equal(t, http.StatusOK, doJSONWithToken(router, token, "POST", "/me/email/verification", nil).Code)
+	link := lastLink("alice@example.com")
+	assert.NotEqual(t, firstLink, link)
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "GET", "/verify-email?token=nope", nil).Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "GET", link, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "GET", link, nil).Code)
+	assert.Equal(t, http.StatusOK, doJSONWithToken(router, token, "POST", "/posts", nil).Code)
+	assert.Equal(t, http.StatusConflict, doJSONWithToken(router, token, "POST", "/me/email/verification", nil).Code)
+
+	// Changing address takes the password and a confirmation from the new
+	// address, and the old one is told.
+	change := func(email, password string) int {
+		t.Helper()
+		return doJSONWithToken(router, token, "POST", "/me/email", gin.H{"email": email, "password": password}).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, change("alice@new.example.com", "wrong"))
+	assert.Equal(t, http.StatusConflict, change("bob@example.com", "Passw0rd!"))
+	assert.Equal(t, http.StatusBadRequest, change("alice@example.com", "Passw0rd!"))
+	require.Equal(t, http.StatusOK, change("alice@new.example.com", "Passw0rd!"))
+	changeLink := lastLink("alice@new.example.com")
+
+	var email string
+	require.NoError(t, db.QueryRow("SELECT email FROM users WHERE username = 'alice'").Scan(&email))
+	assert.Equal(t, "alice@example.com", email)
+
+	require.Equal(t, http.StatusOK, doJSON(router, "GET", changeLink, nil).Code)
+	require.NoError(t, db.QueryRow("SELECT email FROM users WHERE username = 'alice'").Scan(&email))
+	assert.Equal(t, "alice@new.example.com", email)
+	sent := mailer.messages()
+	assert.Equal(t, "alice@example.com", sent[len(sent)-1].To)
+	assert.Contains(t, sent[len(sent)-1].Body, "alice@new.example.com")
+
+	// Links for the old address are void.
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "GET", firstLink, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "GET", changeLink, nil).Code)
+}
+
+func TestChangeEmailThrottled(t *testing.T) {
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "alice")
+	setTestPassword(t, db, userID)
+
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:        signing.NewHMAC("test-secret"),
+		Mailer:      &recordingMailer{},
+		LoginLimits: LoginLimits{MaxFailures: 2, LockoutDuration: time.Hour},
+	})
+	router := gin.New()
+	router.POST("/me/email", withUser(userID), auth.ChangeEmail)
+
+	change := func(password string) *httptest.ResponseRecorder {
+		return doJSON(router, "POST", "/me/email", gin.H{"email": "alice@new.example.com", "password": password})
+	}
+
+	// Guessing the password with a stolen access token locks the account
+	// like failed logins do, even for the right password afterwards.
+	assert.Equal(t, http.StatusBadRequest, change("Wrong#Horse1").Code)
+	assert.Equal(t, http.StatusBadRequest, change("Wrong#Horse2").Code)
+	w := change(throttleTestPassword)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+var errInvalidIfMatch = errors.New("invalid If-Match header")
+
+// postETag is a strong entity tag built from the post's version counter.
+func postETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion returns the version If-Match asks for, 0 for none or "*",
+// and -1 for well-formed tags that can never match, weak ones included.
+func ifMatchVersion(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, errInvalidIfMatch
+	}
+
+	tag, weak := strings.CutPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 || weak {
+		return -1, nil
+	}
+	return version, nil
+}
+
+// respondWithConflict answers a stale conditional update with 412 and the
+// post as it currently stands, so the client can merge and retry.
+func (h *PostHandler) respondWithConflict(c *gin.Context, id int64, viewer policy.Actor) {
+	post, err := h.getPostByID(id, viewer)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch post")
+		return
+	}
+
+	if err := loadPostTags(h.db, []*models.Post{post}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	c.Header("ETag", postETag(post.Version))
+	c.JSON(http.StatusPreconditionFailed, utils.Response{
+		Status: "error",
+		Error:  "Post has been modified since it was loaded",
+		Data:   post,
+	})
+}
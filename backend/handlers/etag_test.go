@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"*", 0, false},
+		{`"3"`, 3, false},
+		{`W/"3"`, -1, false},
+		{`"abc"`, -1, false},
+		{`3`, 0, true},
+		{`"1", "2"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		version, err := ifMatchVersion(tt.header)
+		if tt.wantErr {
+			assert.Error(t, err, tt.header)
+			continue
+		}
+		require.NoError(t, err, tt.header)
+		assert.Equal(t, tt.version, version, tt.header)
+	}
+}
+
+func TestUpdatePostIfMatch(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	insertTestPost(t, db, authorID, "shared", "Shared post", "Original content.")
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	author := router.Group("/author", withUser(authorID))
+	author.GET("/posts/:id", posts.GetPost)
+	author.PUT("/posts/:id", posts.UpdatePost)
+
+	put := func(ifMatch, content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.PostInput{Title: "Shared post", Content: content})
+		req := httptest.NewRequest("PUT", "/author/posts/1", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := doJSON(router, "GET", "/author/posts/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	// If-Match compares strongly, so a weak tag never matches.
+	assert.Equal(t, http.StatusPreconditionFailed, put(`W/"1"`, "Edited from a weak tag.").Code)
+
+	// Both tabs loaded version 1; the first save wins.
+	w = put(etag, "Edited in the first tab.")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	w = put(etag, "Edited in the second tab.")
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	var current models.Post
+	decodeData(t, w, &current)
+	assert.Equal(t, int64(2), current.Version)
+	assert.Equal(t, "Edited in the first tab.", current.Content)
+
+	assert.Equal(t, http.StatusBadRequest, put("2", "Unquoted tag.").Code)
+
+	// Without If-Match the update is unconditional, as before.
+	w = put("", "Last writer wins.")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// GetFeed lists posts from the accounts the caller follows, most recently
+// published first, paged by a cursor that stays stable as posts arrive.
+func (h *PostHandler) GetFeed(c *gin.Context) {
+	_, pageSize, _ := utils.GetPagination(c)
+
+	before, err := decodeCursor(c.Query("cursor"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+
+	posts, keys, err := h.getFeed(userID, before, pageSize+1)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch feed")
+		return
+	}
+
+	nextCursor := ""
+	if len(posts) > pageSize {
+		posts = posts[:pageSize]
+		nextCursor = encodeCursor(keys[pageSize-1])
+	}
+
+	if err := loadPostTags(h.db, posts); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+	if err := loadPostLikes(h.db, posts, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch likes")
+		return
+	}
+
+	utils.CursorSuccessResponse(c, posts, nextCursor)
+}
+
+// feedCursor is the published_at, as stored, and ID of the last post seen.
+type feedCursor struct {
+	publishedAt string
+	id          int64
+}
+
+func encodeCursor(cursor feedCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(cursor.id, 10) + "|" + cursor.publishedAt))
+}
+
+// decodeCursor returns the zero feedCursor for an empty cursor, meaning
+// "start from the top".
+func decodeCursor(cursor string) (feedCursor, error) {
+	if cursor == "" {
+		return feedCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return feedCursor{}, errInvalidCursor
+	}
+
+	idText, publishedAt, ok := strings.Cut(string(raw), "|")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if !ok || err != nil || id <= 0 || publishedAt == "" {
+		return feedCursor{}, errInvalidCursor
+	}
+	return feedCursor{publishedAt: publishedAt, id: id}, nil
+}
+
+func (h *PostHandler) getFeed(userID int64, before feedCursor, limit int) ([]*models.Post, []feedCursor, error) {
+	rows, err := h.db.Query(`
+        SELECT p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.slug, p.status, p.published_at,
+               CAST(p.published_at AS TEXT), p.created_at, p.updated_at, u.username, u.email
+        FROM posts p
+        JOIN follows f ON f.followee_id = p.user_id AND f.follower_id = ?
+        JOIN users u ON p.user_id = u.id
+        WHERE `+publishedPostFilter+`
+          AND (? = 0 OR p.published_at < ? OR (p.published_at = ? AND p.id < ?))
+        ORDER BY p.published_at DESC, p.id DESC
+        LIMIT ?
+    `, userID, before.id, before.publishedAt, before.publishedAt, before.id, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	keys := []feedCursor{}
+	for rows.Next() {
+		post := &models.Post{Author: &models.User{}}
+		var key feedCursor
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.ContentHTML,
+			&post.Slug,
+			&post.Status,
+			&post.PublishedAt,
+			&key.publishedAt,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Author.Username,
+			&post.Author.Email,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		key.id = post.ID
+		posts = append(posts, post)
+		keys = append(keys, key)
+	}
+	return posts, keys, rows.Err()
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/database"
+	"github.com/prem0x01/Blogy/database/migrations"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDatabase opens an in-memory database with the full schema and all
+// migrations applied. A single connection keeps every query on the same
+// in-memory database.
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.NewDatabase(":memory:", &database.Config{
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, migrations.RunMigrations(db.DB))
+	return db
+}
+
+func insertTestUser(t *testing.T, db *database.Database, username string) int64 {
+	t.Helper()
+
+	result, err := db.Exec(`
+		INSERT INTO users (username, email, password_hash)
+		VALUES (?, ?, 'x')
+	`, username, username+"@example.com")
+	require.NoError(t, err)
+
+	id, err := result.LastInsertId()
+	require.NoError(t, err)
+	return id
+}
+
+func insertTestPost(t *testing.T, db *database.Database, userID int64, slug, title, content string) int64 {
+	t.Helper()
+
+	result, err := db.Exec(`
+		INSERT INTO posts (user_id, title, content, slug, published_at)
+		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, userID, title, content, slug)
+	require.NoError(t, err)
+
+	id, err := result.LastInsertId()
+	require.NoError(t, err)
+	return id
+}
+
+// withUser stands in for AuthMiddleware in handler tests.
+func withUser(userID int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	}
+}
+
+func withActor(userID int64, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+func doJSON(router *gin.Engine, method, url string, body interface{}) *httptest.ResponseRecorder {
+	return doJSONWithToken(router, "", method, url, body)
+}
+
+// doJSONWithToken is doJSON with a bearer token, for tests that go through
+// the real AuthMiddleware.
+func doJSONWithToken(router *gin.Engine, token, method, url string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody []byte
+	if body != nil {
+		reqBody, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, url, bytes.NewBuffer(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeData unmarshals the data field of a utils.Response envelope.
+func decodeData(t *testing.T, w *httptest.ResponseRecorder, target interface{}) {
+	t.Helper()
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &envelope))
+	require.NoError(t, json.Unmarshal(envelope.Data, target))
+}
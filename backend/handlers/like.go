@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+type LikeHandler struct {
+	db *sql.DB
+}
+
+func NewLikeHandler(db *sql.DB) *LikeHandler {
+	return &LikeHandler{db: db}
+}
+
+// Like and unlike are idempotent: liking twice or unliking something that
+// was never liked succeeds and reports the current state.
+
+func (h *LikeHandler) LikePost(c *gin.Context) {
+	h.setPostLike(c, true)
+}
+
+func (h *LikeHandler) UnlikePost(c *gin.Context) {
+	h.setPostLike(c, false)
+}
+
+func (h *LikeHandler) LikeComment(c *gin.Context) {
+	h.setCommentLike(c, true)
+}
+
+func (h *LikeHandler) UnlikeComment(c *gin.Context) {
+	h.setCommentLike(c, false)
+}
+
+func (h *LikeHandler) setPostLike(c *gin.Context, liked bool) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+
+	var exists bool
+	err = h.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM posts p WHERE p.id = ? AND "+publishedPostFilter+")",
+		postID,
+	).Scan(&exists)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	if liked {
+		_, err = h.db.Exec("INSERT OR IGNORE INTO likes (user_id, post_id) VALUES (?, ?)", userID, postID)
+	} else {
+		_, err = h.db.Exec("DELETE FROM likes WHERE user_id = ? AND post_id = ?", userID, postID)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update like")
+		return
+	}
+
+	var count int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM likes WHERE post_id = ?", postID).Scan(&count); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"liked": liked, "like_count": count})
+}
+
+func (h *LikeHandler) setCommentLike(c *gin.Context, liked bool) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+		return
+	}
+
+	commentID, err := strconv.ParseInt(c.Param("commentId"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+
+	var exists bool
+	err = h.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.id = ? AND c.post_id = ? AND c.status = 'approved' AND `+publishedPostFilter+`
+		)
+	`, commentID, postID).Scan(&exists)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		utils.ErrorResponse(c, http.StatusNotFound, "Comment not found")
+		return
+	}
+
+	if liked {
+		_, err = h.db.Exec("INSERT OR IGNORE INTO comment_likes (user_id, comment_id) VALUES (?, ?)", userID, commentID)
+	} else {
+		_, err = h.db.Exec("DELETE FROM comment_likes WHERE user_id = ? AND comment_id = ?", userID, commentID)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update like")
+		return
+	}
+
+	var count int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM comment_likes WHERE comment_id = ?", commentID).Scan(&count); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"liked": liked, "like_count": count})
+}
+
+// loadPostLikes fills in LikeCount on each post, and LikedByMe when
+// viewerID is a signed-in user.
+func loadPostLikes(db *sql.DB, posts []*models.Post, viewerID int64) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Post, len(posts))
+	args := []interface{}{viewerID}
+	for _, post := range posts {
+		byID[post.ID] = post
+		args = append(args, post.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT post_id, COUNT(*), MAX(user_id = ?)
+		FROM likes
+		WHERE post_id IN (`+placeholders(len(posts))+`)
+		GROUP BY post_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, count int64
+		var likedByMe bool
+		if err := rows.Scan(&postID, &count, &likedByMe); err != nil {
+			return err
+		}
+		if post, ok := byID[postID]; ok {
+			post.LikeCount = count
+			post.LikedByMe = likedByMe
+		}
+	}
+	return rows.Err()
+}
+
+// loadCommentLikes is the comment counterpart of loadPostLikes.
+func loadCommentLikes(db *sql.DB, comments []*models.Comment, viewerID int64) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Comment, len(comments))
+	args := []interface{}{viewerID}
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+		args = append(args, comment.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT comment_id, COUNT(*), MAX(user_id = ?)
+		FROM comment_likes
+		WHERE comment_id IN (`+placeholders(len(comments))+`)
+		GROUP BY comment_id
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID, count int64
+		var likedByMe bool
+		if err := rows.Scan(&commentID, &count, &likedByMe); err != nil {
+			return err
+		}
+		if comment, ok := byID[commentID]; ok {
+			comment.LikeCount = count
+			comment.LikedByMe = likedByMe
+		}
+	}
+	return rows.Err()
+}
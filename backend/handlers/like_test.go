@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLikes(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	readerID := insertTestUser(t, db, "reader")
+	postID := insertTestPost(t, db, authorID, "liked", "A likeable post", "Some content here.")
+	_, err := db.Exec("INSERT INTO comments (post_id, user_id, content) VALUES (?, ?, 'Nice')", postID, authorID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	likes := NewLikeHandler(db.DB)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	reader := router.Group("", withUser(readerID))
+	reader.POST("/posts/:id/like", likes.LikePost)
+	reader.DELETE("/posts/:id/like", likes.UnlikePost)
+	reader.POST("/posts/:id/comments/:commentId/like", likes.LikeComment)
+	reader.GET("/posts/:id", posts.GetPost)
+	router.GET("/anon/posts/:id", posts.GetPost)
+
+	var state struct {
+		Liked     bool  `json:"liked"`
+		LikeCount int64 `json:"like_count"`
+	}
+
+	for i := 0; i < 2; i++ {
+		w := doJSON(router, "POST", "/posts/1/like", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		decodeData(t, w, &state)
+		assert.True(t, state.Liked)
+		assert.Equal(t, int64(1), state.LikeCount, "liking twice counts once")
+	}
+
+	w := doJSON(router, "POST", "/posts/1/comments/1/like", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(router, "POST", "/posts/2/comments/1/like", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var post models.Post
+	w = doJSON(router, "GET", "/posts/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Equal(t, int64(1), post.LikeCount)
+	assert.True(t, post.LikedByMe)
+	require.Len(t, post.Comments, 1)
+	assert.Equal(t, int64(1), post.Comments[0].LikeCount)
+	assert.True(t, post.Comments[0].LikedByMe)
+
+	w = doJSON(router, "GET", "/anon/posts/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	post = models.Post{}
+	decodeData(t, w, &post)
+	assert.Equal(t, int64(1), post.LikeCount)
+	assert.False(t, post.LikedByMe)
+
+	for i := 0; i < 2; i++ {
+		w = doJSON(router, "DELETE", "/posts/1/like", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		decodeData(t, w, &state)
+		assert.False(t, state.Liked)
+		assert.Equal(t, int64(0), state.LikeCount)
+	}
+}
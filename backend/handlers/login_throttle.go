@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	loginLockoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_lockouts_total",
+			Help: "Total number of accounts and addresses locked out after too many failed logins",
+		},
+		[]string{"scope"},
+	)
+
+	loginThrottledTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_throttled_total",
+			Help: "Total number of login attempts refused because of backoff or a lockout",
+		},
+		[]string{"scope"},
+	)
+)
+
+// LoginLimits slows down password guessing per account and address. Zero
+// values turn the corresponding limit off.
+type LoginLimits struct {
+	MaxFailures     int
+	MaxIPFailures   int
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+}
+
+func (l LoginLimits) enabled() bool {
+	return l.MaxFailures > 0 || l.MaxIPFailures > 0 || l.BaseDelay > 0
+}
+
+// throttleKey is what failures are counted against: an account or a client
+// address.
+type throttleKey struct {
+	scope string
+	id    string
+}
+
+func accountThrottleKey(userID int64) throttleKey {
+	return throttleKey{scope: "account", id: strconv.FormatInt(userID, 10)}
+}
+
+// unknownAccountThrottleKey counts failures for identifiers that match no
+// account, so that those are refused in the same way as real ones.
+func unknownAccountThrottleKey(identifier string) throttleKey {
+	return throttleKey{scope: "account", id: "?" + strings.ToLower(identifier)}
+}
+
+func ipThrottleKey(ip string) throttleKey {
+	return throttleKey{scope: "ip", id: ip}
+}
+
+func (k throttleKey) String() string {
+	return k.scope + ":" + k.id
+}
+
+func (l LoginLimits) maxFailures(scope string) int {
+	if scope == "ip" {
+		return l.MaxIPFailures
+	}
+	return l.MaxFailures
+}
+
+// delay is how long to wait after the nth failure in a row.
+func (l LoginLimits) delay(n int) time.Duration {
+	if l.BaseDelay <= 0 {
+		return 0
+	}
+	d := l.BaseDelay
+	for i := 1; i < n && (l.MaxDelay <= 0 || d < l.MaxDelay) && d < math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if l.MaxDelay > 0 && d > l.MaxDelay {
+		d = l.MaxDelay
+	}
+	return d
+}
+
+// loginWait reports how long until any of keys may try to log in again,
+// and which of them is holding things up.
+func (h *AuthHandler) loginWait(keys ...throttleKey) (time.Duration, throttleKey, error) {
+	var wait time.Duration
+	var blocking throttleKey
+	if !h.loginLimits.enabled() {
+		return 0, blocking, nil
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		var blockedUntil time.Time
+		err := h.db.QueryRow(
+			"SELECT blocked_until FROM login_throttles WHERE throttle_key = ?",
+			key.String(),
+		).Scan(&blockedUntil)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return 0, blocking, err
+		}
+		if d := blockedUntil.Sub(now); d > wait {
+			wait, blocking = d, key
+		}
+	}
+	return wait, blocking, nil
+}
+
+// loginAttempt is counted as a failure before the credentials are checked,
+// so concurrent guesses cannot slip past the limits.
+type loginAttempt struct {
+	h            *AuthHandler
+	keys         []throttleKey
+	failures     []int
+	blockedUntil []time.Time
+}
+
+// startLoginAttempt counts an attempt against keys, or answers 429, and
+// reports whether it may go on. Settle it with fail or succeed.
+func (h *AuthHandler) startLoginAttempt(c *gin.Context, keys ...throttleKey) (*loginAttempt, bool) {
+	attempt := &loginAttempt{h: h}
+	if !h.loginLimits.enabled() {
+		return attempt, true
+	}
+
+	counted, err := attempt.count(keys)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	if counted {
+		return attempt, true
+	}
+
+	wait, key, err := h.loginWait(keys...)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	loginThrottledTotal.WithLabelValues(key.scope).Inc()
+	c.Header("Retry-After", fmt.Sprint(max(1, int(math.Ceil(wait.Seconds())))))
+	utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	return nil, false
+}
+
+// count adds a failure for each of keys unless one of them is blocked.
+func (a *loginAttempt) count(keys []throttleKey) (bool, error) {
+	limits := a.h.loginLimits
+	now := time.Now().UTC()
+	tx, err := a.h.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if limits.LockoutDuration > 0 {
+		if _, err := tx.Exec(
+			"DELETE FROM login_throttles WHERE last_failure_at < ?",
+			now.Add(-limits.LockoutDuration),
+		); err != nil {
+			return false, err
+		}
+	}
+
+	for _, key := range keys {
+		// Checking and counting in one statement keeps concurrent
+		// attempts from missing each other's failures.
+		var failures int
+		err := tx.QueryRow(`
+			INSERT INTO login_throttles (throttle_key, failures, last_failure_at, blocked_until)
+			VALUES (?, 1, ?, ?)
+			ON CONFLICT (throttle_key) DO UPDATE SET
+				failures = failures + 1,
+				last_failure_at = excluded.last_failure_at
+			WHERE blocked_until <= excluded.last_failure_at
+			RETURNING failures
+		`, key.String(), now, now).Scan(&failures)
+		if err == sql.ErrNoRows {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		blockedUntil := now.Add(limits.delay(failures))
+		if max := limits.maxFailures(key.scope); max > 0 && failures >= max {
+			blockedUntil = now.Add(limits.LockoutDuration)
+		}
+		if _, err := tx.Exec(
+			"UPDATE login_throttles SET blocked_until = ? WHERE throttle_key = ?",
+			blockedUntil, key.String(),
+		); err != nil {
+			return false, err
+		}
+
+		a.keys = append(a.keys, key)
+		a.failures = append(a.failures, failures)
+		a.blockedUntil = append(a.blockedUntil, blockedUntil)
+	}
+
+	return true, tx.Commit()
+}
+
+// fail settles an attempt that failed. The failure is already counted;
+// only the lockouts it caused are left to report.
+func (a *loginAttempt) fail() {
+	for i, key := range a.keys {
+		if max := a.h.loginLimits.maxFailures(key.scope); max > 0 && a.failures[i] >= max {
+			loginLockoutsTotal.WithLabelValues(key.scope).Inc()
+		}
+	}
+}
+
+// succeed clears the account's failures but only takes back this attempt's
+// failure from addresses, which may be guessing at other accounts.
+func (a *loginAttempt) succeed() error {
+	for i, key := range a.keys {
+		if key.scope != "ip" {
+			if err := a.h.clearLoginFailures(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := a.h.db.Exec(`
+			UPDATE login_throttles
+			SET failures = failures - 1,
+				blocked_until = CASE WHEN blocked_until = ? THEN ? ELSE blocked_until END
+			WHERE throttle_key = ?
+		`, a.blockedUntil[i], time.Now().UTC(), key.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures counted against key.
+func (h *AuthHandler) clearLoginFailures(key throttleKey) error {
+	if !h.loginLimits.enabled() {
+		return nil
+	}
+	_, err := h.db.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", key.String())
+	return err
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/database"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/passhash"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const throttleTestPassword = "Correct#Horse1"
+
+func newLoginRouter(t *testing.T, db *database.Database, limits LoginLimits) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:        signing.NewHMAC("test-secret"),
+		Mailer:      &recordingMailer{},
+		LoginLimits: limits,
+	})
+	router := gin.New()
+	router.POST("/login", auth.Login)
+	return router
+}
+
+func setTestPassword(t *testing.T, db *database.Database, userID int64) {
+	t.Helper()
+
+	var user models.User
+	require.NoError(t, user.SetPassword(passhash.NewArgon2id(passhash.DefaultArgon2idParams), throttleTestPassword))
+	_, err := db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", user.PasswordHash, userID)
+	require.NoError(t, err)
+}
+
+func TestLoginLockout(t *testing.T) {
+	db := newTestDatabase(t)
+	alice := insertTestUser(t, db, "alice")
+	bob := insertTestUser(t, db, "bob")
+	setTestPassword(t, db, alice)
+	setTestPassword(t, db, bob)
+
+	router := newLoginRouter(t, db, LoginLimits{
+		MaxFailures:     3,
+		MaxIPFailures:   6,
+		LockoutDuration: time.Hour,
+	})
+	login := func(identifier, password string) *http.Response {
+		return doJSON(router, "POST", "/login", gin.H{"identifier": identifier, "password": password}).Result()
+	}
+	accountLockouts := testutil.ToFloat64(loginLockoutsTotal.WithLabelValues("account"))
+	ipLockouts := testutil.ToFloat64(loginLockoutsTotal.WithLabelValues("ip"))
+
+	// Signing in clears the account's failures.
+	assert.Equal(t, http.StatusUnauthorized, login("alice", "wrong").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, login("alice", "wrong").StatusCode)
+	assert.Equal(t, http.StatusOK, login("alice", throttleTestPassword).StatusCode)
+
+	// Failures by username and by email address count against the same
+	// account, which is then locked even for the right password.
+	assert.Equal(t, http.StatusUnauthorized, login("alice", "wrong").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, login("alice", "wrong").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, login("alice@example.com", "wrong").StatusCode)
+	resp := login("alice", throttleTestPassword)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "3600", resp.Header.Get("Retry-After"))
+	assert.Equal(t, accountLockouts+1, testutil.ToFloat64(loginLockoutsTotal.WithLabelValues("account")))
+
+	// Other accounts still work until the address has failed too often,
+	// and unknown identifiers count against it as well.
+	assert.Equal(t, http.StatusOK, login("bob", throttleTestPassword).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, login("nobody", "wrong").StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, login("bob", throttleTestPassword).StatusCode)
+	assert.Equal(t, ipLockouts+1, testutil.ToFloat64(loginLockoutsTotal.WithLabelValues("ip")))
+}
+
+func TestLoginBackoff(t *testing.T) {
+	db := newTestDatabase(t)
+	alice := insertTestUser(t, db, "alice")
+	setTestPassword(t, db, alice)
+
+	router := newLoginRouter(t, db, LoginLimits{BaseDelay: time.Minute})
+
+	w := doJSON(router, "POST", "/login", gin.H{"identifier": "alice", "password": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doJSON(router, "POST", "/login", gin.H{"identifier": "alice", "password": throttleTestPassword})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestLoginBackoffSparesAddressOnSuccess(t *testing.T) {
+	db := newTestDatabase(t)
+	alice := insertTestUser(t, db, "alice")
+	bob := insertTestUser(t, db, "bob")
+	setTestPassword(t, db, alice)
+	setTestPassword(t, db, bob)
+
+	router := newLoginRouter(t, db, LoginLimits{BaseDelay: time.Minute})
+
+	// Attempts are counted before the password is checked, and taken back
+	// from the address when it was right, so people sharing one can each
+	// sign in.
+	w := doJSON(router, "POST", "/login", gin.H{"identifier": "alice", "password": throttleTestPassword})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doJSON(router, "POST", "/login", gin.H{"identifier": "bob", "password": throttleTestPassword})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLoginBurstCannotExceedLimit(t *testing.T) {
+	db := newTestDatabase(t)
+	alice := insertTestUser(t, db, "alice")
+	setTestPassword(t, db, alice)
+
+	router := newLoginRouter(t, db, LoginLimits{MaxFailures: 3, LockoutDuration: time.Hour})
+
+	// Concurrent guesses each count before any is verified, so no more
+	// than the limit get to try a password.
+	const attempts = 10
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- doJSON(router, "POST", "/login", gin.H{"identifier": "alice", "password": "wrong"}).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	tried := 0
+	for code := range codes {
+		if code == http.StatusUnauthorized {
+			tried++
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, code)
+		}
+	}
+	assert.Equal(t, 3, tried)
+}
+
+func TestLoginUnknownAccountChecksDummyHash(t *testing.T) {
+	db := newTestDatabase(t)
+	auth := NewAuthHandler(db.DB, AuthOptions{Keys: signing.NewHMAC("test-secret")})
+
+	// Unknown identifiers are checked against a real hash, so they take
+	// as long as a wrong password does.
+	hash := auth.dummyPasswordHash()
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"), hash)
+	assert.Equal(t, hash, auth.dummyPasswordHash())
+}
+
+func TestLoginLimitsDelay(t *testing.T) {
+	limits := LoginLimits{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, limits.delay(1))
+	assert.Equal(t, 2*time.Second, limits.delay(2))
+	assert.Equal(t, 8*time.Second, limits.delay(4))
+	assert.Equal(t, 10*time.Second, limits.delay(5))
+	assert.Equal(t, 10*time.Second, limits.delay(1000))
+	assert.Zero(t, LoginLimits{}.delay(3))
+}
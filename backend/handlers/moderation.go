@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// ModerationHandler works through comments held for moderation, by post
+// owners on their own posts and by moderators on every post.
+type ModerationHandler struct {
+	db   *sql.DB
+	spam SpamChecker
+}
+
+func NewModerationHandler(db *sql.DB, spam SpamChecker) *ModerationHandler {
+	return &ModerationHandler{db: db, spam: spam}
+}
+
+// GetQueue lists held comments, oldest first. ?status=rejected lists
+// rejected ones instead and ?post_id= narrows it to one post.
+func (h *ModerationHandler) GetQueue(c *gin.Context) {
+	status := c.DefaultQuery("status", models.CommentStatusPending)
+	if status != models.CommentStatusPending && status != models.CommentStatusRejected {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	actor := actorFrom(c)
+	where := "c.status = ? AND (? OR p.user_id = ?)"
+	args := []interface{}{status, actor.Can(policy.ModerateComments), actor.UserID}
+	if value := c.Query("post_id"); value != "" {
+		postID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+			return
+		}
+		where += " AND c.post_id = ?"
+		args = append(args, postID)
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+
+	var total int64
+	err := h.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch moderation queue")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT `+commentColumns+`, c.spam_score
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		JOIN users u ON c.user_id = u.id
+		WHERE `+where+`
+		ORDER BY c.created_at, c.id
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, offset)...)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch moderation queue")
+		return
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		var spamScore sql.NullFloat64
+		comment, err := scanComment(rows, &spamScore)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch moderation queue")
+			return
+		}
+		if spamScore.Valid {
+			comment.SpamScore = &spamScore.Float64
+		}
+		comments = append(comments, comment)
+	}
+
+	utils.PaginatedSuccessResponse(c, comments, total, page, pageSize)
+}
+
+func (h *ModerationHandler) ApproveComment(c *gin.Context) {
+	h.moderateOne(c, models.CommentStatusApproved)
+}
+
+func (h *ModerationHandler) RejectComment(c *gin.Context) {
+	h.moderateOne(c, models.CommentStatusRejected)
+}
+
+// BulkModerate approves or rejects several comments, skipping those it
+// cannot apply to, and reports how many were updated.
+func (h *ModerationHandler) BulkModerate(c *gin.Context) {
+	var input models.ModerationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	status := models.CommentStatusApproved
+	if input.Action == "reject" {
+		status = models.CommentStatusRejected
+	}
+
+	updated, err := h.setCommentStatus(input.CommentIDs, status, actorFrom(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to moderate comments")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"updated": updated})
+}
+
+func (h *ModerationHandler) moderateOne(c *gin.Context, status string) {
+	commentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	updated, err := h.setCommentStatus([]int64{commentID}, status, actorFrom(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to moderate comment")
+		return
+	}
+	if updated == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Comment not found in moderation queue")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"id": commentID, "status": status})
+}
+
+// setCommentStatus moves the comments moderator may moderate to status.
+// Only site moderators train the spam checker, whose model is shared.
+// Approved comments may have replies, so only pending ones can be rejected.
+func (h *ModerationHandler) setCommentStatus(ids []int64, status string, moderator policy.Actor) (int64, error) {
+	from := "'pending', 'rejected'"
+	if status == models.CommentStatusRejected {
+		from = "'pending'"
+	}
+
+	args := make([]interface{}, 0, len(ids)+2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, moderator.Can(policy.ModerateComments), moderator.UserID)
+	where := `
+		WHERE id IN (` + placeholders(len(ids)) + `)
+		  AND status IN (` + from + `)
+		  AND deleted_at IS NULL
+		  AND (? OR post_id IN (SELECT id FROM posts WHERE user_id = ?))`
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, content FROM comments"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	contents := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		contents[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE comments SET status = ?"+where, append([]interface{}{status}, args...)...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if h.spam != nil && moderator.Can(policy.ModerateComments) {
+		for id, content := range contents {
+			if err := h.spam.Learn(id, content, status == models.CommentStatusRejected); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return int64(len(contents)), nil
+}
+
+// UpdateCommentSettings lets whoever manages a post pick its moderation mode and
+// lock or unlock its comments.
+func (h *PostHandler) UpdateCommentSettings(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.CommentSettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if input.Mode != nil && *input.Mode != "" && !models.ValidCommentMode(*input.Mode) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid comment mode")
+		return
+	}
+
+	// A nil field leaves the setting as it is; an empty mode clears the
+	// override so the site-wide mode applies again.
+	var mode interface{}
+	if input.Mode != nil {
+		mode = *input.Mode
+	}
+
+	_, err := h.db.Exec(`
+		UPDATE posts
+		SET comment_mode = CASE WHEN ? IS NULL THEN comment_mode ELSE NULLIF(?, '') END,
+		    comments_locked = COALESCE(?, comments_locked)
+		WHERE id = ?
+	`, mode, mode, input.Locked, postID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update comment settings")
+		return
+	}
+
+	h.respondWithPost(c, postID, actorFrom(c))
+}
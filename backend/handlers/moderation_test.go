@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentModeration(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	newcomerID := insertTestUser(t, db, "newcomer")
+	regularID := insertTestUser(t, db, "regular")
+	insertTestPost(t, db, authorID, "moderated", "Moderated", "Some content here.")
+	_, err := db.Exec("INSERT INTO comments (post_id, user_id, content) VALUES (1, ?, 'earlier comment')", regularID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+	comments := NewCommentHandler(db.DB, CommentOptions{
+		TreeDepth:        1,
+		RepliesPerBranch: 5,
+		DefaultMode:      models.CommentModeHoldFirstTime,
+	})
+	moderation := NewModerationHandler(db.DB, nil)
+
+	router := gin.New()
+	router.GET("/posts/:id/comments", comments.GetComments)
+	for name, id := range map[string]int64{"author": authorID, "newcomer": newcomerID, "regular": regularID} {
+		group := router.Group("/"+name, withUser(id))
+		group.GET("/posts/:id/comments", comments.GetComments)
+		group.POST("/posts/:id/comments", comments.CreateComment)
+		group.PUT("/posts/:id/comment-settings", posts.UpdateCommentSettings)
+		group.GET("/moderation/comments", moderation.GetQueue)
+		group.POST("/moderation/comments", moderation.BulkModerate)
+		group.POST("/moderation/comments/:id/approve", moderation.ApproveComment)
+		group.POST("/moderation/comments/:id/reject", moderation.RejectComment)
+	}
+
+	create := func(user, content string) models.Comment {
+		t.Helper()
+		w := doJSON(router, "POST", "/"+user+"/posts/1/comments", gin.H{"content": content})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var comment models.Comment
+		decodeData(t, w, &comment)
+		return comment
+	}
+	listed := func(path string) int64 {
+		t.Helper()
+		var page commentPage
+		w := doJSON(router, "GET", path, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		decodeData(t, w, &page)
+		return page.TotalItems
+	}
+
+	// First-time commenters are held; people with an approved comment are not.
+	held := create("newcomer", "hello there")
+	assert.Equal(t, models.CommentStatusPending, held.Status)
+	assert.Equal(t, models.CommentStatusApproved, create("regular", "welcome back").Status)
+
+	assert.Equal(t, int64(2), listed("/posts/1/comments"))
+	assert.Equal(t, int64(3), listed("/newcomer/posts/1/comments"))
+
+	var queue commentPage
+	w := doJSON(router, "GET", "/author/moderation/comments", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &queue)
+	require.Len(t, queue.Items, 1)
+	assert.Equal(t, held.ID, queue.Items[0].ID)
+
+	// Only the post owner can moderate its comments.
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "POST", "/regular/moderation/comments/2/approve", nil).Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "POST", "/author/moderation/comments/2/approve", nil).Code)
+	assert.Equal(t, int64(3), listed("/posts/1/comments"))
+	assert.Equal(t, models.CommentStatusApproved, create("newcomer", "thanks!").Status)
+
+	// Holding everything, then rejecting in bulk.
+	w = doJSON(router, "PUT", "/author/posts/1/comment-settings", gin.H{"mode": models.CommentModeHoldAll})
+	require.Equal(t, http.StatusOK, w.Code)
+	first := create("regular", "one")
+	second := create("newcomer", "two")
+	assert.Equal(t, models.CommentStatusPending, first.Status)
+	assert.Equal(t, models.CommentStatusApproved, create("author", "owner is never held").Status)
+
+	w = doJSON(router, "POST", "/author/moderation/comments", gin.H{
+		"comment_ids": []int64{first.ID, second.ID, 1},
+		"action":      "reject",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Updated int64 `json:"updated"`
+	}
+	decodeData(t, w, &result)
+	assert.Equal(t, int64(2), result.Updated)
+	assert.Equal(t, int64(2), listed("/author/moderation/comments?status=rejected"))
+
+	// Locking shuts comments off regardless of mode.
+	w = doJSON(router, "PUT", "/author/posts/1/comment-settings", gin.H{"mode": "", "locked": true})
+	require.Equal(t, http.StatusOK, w.Code)
+	var post models.Post
+	decodeData(t, w, &post)
+	assert.Empty(t, post.CommentMode)
+	assert.True(t, post.CommentsLocked)
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "POST", "/regular/posts/1/comments", gin.H{"content": "hi"}).Code)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "PUT", "/regular/posts/1/comment-settings", gin.H{"locked": false}).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "PUT", "/author/posts/1/comment-settings", gin.H{"mode": "bogus"}).Code)
+}
+
+func TestModeratedCommentEdits(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	regularID := insertTestUser(t, db, "regular")
+	insertTestPost(t, db, authorID, "moderated", "Moderated", "Some content here.")
+	_, err := db.Exec(`
+		INSERT INTO comments (post_id, user_id, content) VALUES
+			(1, ?, 'harmless'),
+			(1, ?, 'by the author')
+	`, regularID, authorID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+	comments := NewCommentHandler(db.DB, CommentOptions{
+		TreeDepth:        1,
+		RepliesPerBranch: 5,
+		EditWindow:       time.Hour,
+		DefaultMode:      models.CommentModeHoldFirstTime,
+	})
+
+	router := gin.New()
+	for name, id := range map[string]int64{"author": authorID, "regular": regularID} {
+		group := router.Group("/"+name, withUser(id))
+		group.PUT("/comments/:id", comments.UpdateComment)
+		group.PUT("/posts/:id/comment-settings", posts.UpdateCommentSettings)
+	}
+
+	edit := func(user string, id int, content string) *httptest.ResponseRecorder {
+		return doJSON(router, "PUT", fmt.Sprintf("/%s/comments/%d", user, id), gin.H{"content": content})
+	}
+
+	// An approved comment goes back to moderation when edited, so it cannot
+	// be swapped for something else once approved.
+	w := edit("regular", 1, "now with a link to buy things")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var comment models.Comment
+	decodeData(t, w, &comment)
+	assert.Equal(t, models.CommentStatusPending, comment.Status)
+
+	w = edit("author", 2, "by the author, edited")
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &comment)
+	assert.Equal(t, models.CommentStatusApproved, comment.Status)
+
+	// Neither closed nor locked posts take edits.
+	require.Equal(t, http.StatusOK, doJSON(router, "PUT", "/author/posts/1/comment-settings", gin.H{"mode": models.CommentModeClosed}).Code)
+	assert.Equal(t, http.StatusForbidden, edit("regular", 1, "closed").Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "PUT", "/author/posts/1/comment-settings", gin.H{"mode": models.CommentModeOpen, "locked": true}).Code)
+	assert.Equal(t, http.StatusForbidden, edit("regular", 1, "locked").Code)
+	assert.Equal(t, http.StatusForbidden, edit("author", 2, "locked").Code)
+}
+
+// stubSpamChecker scores anything mentioning "casino" as spam and records
+// what it was taught.
+type stubSpamChecker struct {
+	learned map[string]bool
+}
+
+func (s *stubSpamChecker) Check(userID int64, content string) (float64, error) {
+	if strings.Contains(content, "casino") {
+		return 0.99, nil
+	}
+	return 0.1, nil
+}
+
+func (s *stubSpamChecker) Record(userID int64, content string) error {
+	return nil
+}
+
+func (s *stubSpamChecker) Learn(commentID int64, content string, isSpam bool) error {
+	s.learned[content] = isSpam
+	return nil
+}
+
+func TestSpamCheckedComments(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	readerID := insertTestUser(t, db, "reader")
+	moderatorID := insertTestUser(t, db, "moderator")
+	insertTestPost(t, db, authorID, "spam", "Spam target", "Some content here.")
+
+	checker := &stubSpamChecker{learned: map[string]bool{}}
+
+	gin.SetMode(gin.TestMode)
+	comments := NewCommentHandler(db.DB, CommentOptions{
+		TreeDepth:        1,
+		RepliesPerBranch: 5,
+		EditWindow:       time.Hour,
+		DefaultMode:      models.CommentModeOpen,
+		Spam:             checker,
+		SpamThreshold:    0.8,
+	})
+	moderation := NewModerationHandler(db.DB, checker)
+
+	router := gin.New()
+	reader := router.Group("/reader", withUser(readerID))
+	reader.POST("/posts/:id/comments", comments.CreateComment)
+	reader.PUT("/comments/:id", comments.UpdateComment)
+	author := router.Group("/author", withUser(authorID))
+	author.GET("/moderation/comments", moderation.GetQueue)
+	author.POST("/moderation/comments", moderation.BulkModerate)
+	moderator := router.Group("/moderator", withActor(moderatorID, models.RoleModerator))
+	moderator.POST("/moderation/comments", moderation.BulkModerate)
+
+	var comment models.Comment
+	w := doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": "Nice post"})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &comment)
+	assert.Equal(t, models.CommentStatusApproved, comment.Status)
+	assert.Nil(t, comment.SpamScore, "scores are only shown to moderators")
+
+	w = doJSON(router, "POST", "/reader/posts/1/comments", gin.H{"content": "Visit my casino"})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &comment)
+	assert.Equal(t, models.CommentStatusPending, comment.Status)
+
+	// Editing spam into an approved comment sends it back to the queue.
+	w = doJSON(router, "PUT", "/reader/comments/1", gin.H{"content": "Nice post, also casino"})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &comment)
+	assert.Equal(t, models.CommentStatusPending, comment.Status)
+
+	var queue commentPage
+	w = doJSON(router, "GET", "/author/moderation/comments", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &queue)
+	require.Len(t, queue.Items, 2)
+	require.NotNil(t, queue.Items[0].SpamScore)
+	assert.Equal(t, 0.99, *queue.Items[0].SpamScore)
+
+	// The model is shared by the whole site, so only moderators train it.
+	w = doJSON(router, "POST", "/author/moderation/comments", gin.H{"comment_ids": []int64{2}, "action": "reject"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, checker.learned)
+
+	w = doJSON(router, "POST", "/moderator/moderation/comments", gin.H{"comment_ids": []int64{1, 2}, "action": "approve"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]bool{"Visit my casino": false, "Nice post, also casino": false}, checker.learned)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/mail"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/strength"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+const passwordResetTTL = time.Hour
+
+// ForgotPassword emails a reset link, answering the same whether or not
+// the address has an account.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	var userID int64
+	var username string
+	err := h.db.QueryRow("SELECT id, username FROM users WHERE email = ?", input.Email).Scan(&userID, &username)
+	if err != nil && err != sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err == nil {
+		token, err := h.issuePasswordReset(userID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		// Failing to send is logged but not reported, as that would give
+		// away that the account exists.
+		if err := h.mailer.Send(passwordResetMessage(input.Email, username, h.appURL, token)); err != nil {
+			c.Error(err)
+		}
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message": "If an account uses that email address, a link to reset its password is on its way",
+	})
+}
+
+// ResetPassword sets a new password and signs the account out everywhere.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if !h.validateNewPassword(c, input, input.Password) {
+		return
+	}
+
+	var user models.User
+	if err := user.SetPassword(h.hasher, input.Password); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Password hashing failed")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	err = tx.QueryRow(`
+		SELECT user_id FROM password_resets
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+	`, hashRefreshToken(input.Token), now).Scan(&user.ID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Every link the user was sent stops working, not just this one.
+	if _, err := tx.Exec(
+		"UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		now, user.ID,
+	); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?",
+		user.PasswordHash, now, user.ID,
+	); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if _, err := revokeAllTokens(tx, user.ID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	h.forget(user.ID)
+
+	// Whoever can reset the password owns the account, so a lockout
+	// should not keep them out of it.
+	if err := h.clearLoginFailures(accountThrottleKey(user.ID)); err != nil {
+		c.Error(err)
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Password has been reset, please sign in again"})
+}
+
+// validateNewPassword validates input and rejects weak or breached
+// passwords, answering the request itself when it returns false.
+func (h *AuthHandler) validateNewPassword(c *gin.Context, input interface{}, password string, userInputs ...string) bool {
+	message := ""
+	if err := utils.Validate.Struct(input); err != nil {
+		if !utils.FieldFailed(err, "Password") {
+			utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+			return false
+		}
+		message = utils.FormatValidationErrors(err)
+	} else if max := h.hasher.MaxLength(); max > 0 && len(password) > max {
+		message = fmt.Sprintf("password must be at most %d bytes", max)
+	} else if h.breached.Contains(password) {
+		message = "This password has appeared in a data breach, please choose another"
+	} else {
+		return true
+	}
+
+	utils.ErrorResponseWithData(c, http.StatusBadRequest, message, gin.H{
+		"strength": strength.Estimate(password, userInputs...),
+	})
+	return false
+}
+
+// issuePasswordReset stores a new reset token for userID and returns it.
+// Tokens are random and only their hash is kept, as with refresh tokens.
+func (h *AuthHandler) issuePasswordReset(userID int64) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = h.db.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, hashRefreshToken(token), now, now.Add(passwordResetTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func passwordResetMessage(email, username, appURL, token string) mail.Message {
+	link := appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return mail.Message{
+		To:      email,
+		Subject: "Reset your Blogy password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Blogy account. To choose a new
+one, open this link within the next hour:
+
+%s
+
+If that was not you, you can ignore this email and your password stays as
+it is.
+`, username, link),
+	}
+}
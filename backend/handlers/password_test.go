@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/breach"
+	"github.com/prem0x01/Blogy/mail"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/passhash"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/strength"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recordingMailer keeps what would have been sent.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) messages() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mail.Message(nil), m.sent...)
+}
+
+var resetLink = regexp.MustCompile(`https://blogy\.test/reset-password\?token=(\S+)`)
+
+func TestPasswordReset(t *testing.T) {
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "alice")
+
+	gin.SetMode(gin.TestMode)
+	keys := signing.NewHMAC("test-secret")
+	authenticator := middleware.NewAuthenticator(keys, db.DB, time.Minute)
+	mailer := &recordingMailer{}
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:     keys,
+		Sessions: authenticator,
+		Mailer:   mailer,
+		AppURL:   "https://blogy.test/",
+	})
+	router := gin.New()
+	router.POST("/password/forgot", auth.ForgotPassword)
+	router.POST("/password/reset", auth.ResetPassword)
+	router.POST("/refresh", auth.RefreshToken)
+	router.GET("/me/sessions", middleware.AuthMiddleware(authenticator), auth.GetSessions)
+
+	requestReset := func() string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, doJSON(router, "POST", "/password/forgot", gin.H{"email": "alice@example.com"}).Code)
+		sent := mailer.messages()
+		match := resetLink.FindStringSubmatch(sent[len(sent)-1].Body)
+		require.NotNil(t, match, sent[len(sent)-1].Body)
+		token, err := url.QueryUnescape(match[1])
+		require.NoError(t, err)
+		return token
+	}
+	reset := func(token, password string) int {
+		t.Helper()
+		return doJSON(router, "POST", "/password/reset", gin.H{"token": token, "password": password}).Code
+	}
+
+	// Unknown addresses get the same answer and no email.
+	w := doJSON(router, "POST", "/password/forgot", gin.H{"email": "nobody@example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, mailer.messages())
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "POST", "/password/forgot", gin.H{"email": "nope"}).Code)
+
+	access, refresh, err := auth.generateTokenPair(userID, models.RoleAuthor, "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, doJSONWithToken(router, access, "GET", "/me/sessions", nil).Code)
+
+	stale := requestReset()
+	token := requestReset()
+	require.Len(t, mailer.messages(), 2)
+	assert.Equal(t, "alice@example.com", mailer.messages()[1].To)
+
+	assert.Equal(t, http.StatusBadRequest, reset(token, "weak"))
+	assert.Equal(t, http.StatusBadRequest, reset("not-a-token", "N3w-password"))
+	require.Equal(t, http.StatusOK, reset(token, "N3w-password"))
+
+	// The token, and every other link sent, works once.
+	assert.Equal(t, http.StatusBadRequest, reset(token, "An0ther-password"))
+	assert.Equal(t, http.StatusBadRequest, reset(stale, "An0ther-password"))
+
+	user, err := auth.getUserByID(userID)
+	require.NoError(t, err)
+	assert.True(t, user.CheckPassword("N3w-password"))
+
+	// Everything issued before the reset is signed out.
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, "POST", "/refresh", gin.H{"refreshToken": refresh}).Code)
+	assert.Equal(t, http.StatusUnauthorized, doJSONWithToken(router, access, "GET", "/me/sessions", nil).Code)
+
+	// Signing in straight after the reset works, even within the same second.
+	access, _, err = auth.generateTokenPair(userID, models.RoleAuthor, "test-agent", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, doJSONWithToken(router, access, "GET", "/me/sessions", nil).Code)
+
+	// Links expire.
+	expired := requestReset()
+	_, err = db.Exec("UPDATE password_resets SET expires_at = ?", time.Now().Add(-time.Minute).UTC())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, reset(expired, "An0ther-password"))
+}
+
+func TestNewPasswordChecks(t *testing.T) {
+	db := newTestDatabase(t)
+	insertTestUser(t, db, "alice")
+
+	// A corpus holding one password, as a single file of whole hashes.
+	sum := sha1.Sum([]byte("Password1!"))
+	corpusFile := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(corpusFile, []byte(strings.ToUpper(hex.EncodeToString(sum[:]))+":12345\n"), 0o644))
+	breached, err := breach.Load(corpusFile, "")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	mailer := &recordingMailer{}
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:              signing.NewHMAC("test-secret"),
+		Mailer:            mailer,
+		BreachedPasswords: breached,
+	})
+	router := gin.New()
+	router.POST("/register", auth.Register)
+	router.POST("/password/reset", auth.ResetPassword)
+
+	type rejection struct {
+		Error string `json:"error"`
+		Data  struct {
+			Strength *strength.Result `json:"strength"`
+		} `json:"data"`
+	}
+	register := func(username, password string) (int, rejection) {
+		w := doJSON(router, "POST", "/register", gin.H{
+			"username": username,
+			"email":    username + "@example.com",
+			"password": password,
+		})
+		var body rejection
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	// Breached passwords are refused even though they have every kind of
+	// character, and the answer says how strong the password is.
+	code, body := register("bob", "Password1!")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body.Error, "data breach")
+	require.NotNil(t, body.Data.Strength)
+	assert.Equal(t, 1, body.Data.Strength.Score)
+	assert.Contains(t, body.Data.Strength.Feedback, "This is a commonly used password")
+
+	// So is the strength of passwords the validator refuses.
+	code, body = register("bob", "bob")
+	assert.Equal(t, http.StatusBadRequest, code)
+	require.NotNil(t, body.Data.Strength)
+	assert.Contains(t, body.Data.Strength.Feedback, "Avoid using your name or email address")
+
+	// Passwords have a length limit.
+	code, body = register("bob", "Correct#Horse1"+strings.Repeat("x", 120))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "password must be at most 128 characters", body.Error)
+
+	// Other mistakes come without it.
+	code, body = register("b", "Correct#Horse1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Nil(t, body.Data.Strength)
+
+	code, _ = register("bob", "Correct#Horse1")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Resetting a password is checked the same way.
+	token, err := auth.issuePasswordReset(1)
+	require.NoError(t, err)
+	w := doJSON(router, "POST", "/password/reset", gin.H{"token": token, "password": "Password1!"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "data breach")
+	w = doJSON(router, "POST", "/password/reset", gin.H{"token": token, "password": "Correct#Horse1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestBcryptPasswordLength(t *testing.T) {
+	db := newTestDatabase(t)
+
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:   signing.NewHMAC("test-secret"),
+		Mailer: &recordingMailer{},
+		Hasher: passhash.NewBcrypt(bcrypt.MinCost),
+	})
+	router := gin.New()
+	router.POST("/register", auth.Register)
+
+	register := func(password string) *httptest.ResponseRecorder {
+		return doJSON(router, "POST", "/register", gin.H{
+			"username": "bob",
+			"email":    "bob@example.com",
+			"password": password,
+		})
+	}
+
+	// Bcrypt cannot take the longest passwords the validator allows.
+	w := register("Correct#Horse1" + strings.Repeat("x", 59))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "password must be at most 72 bytes")
+
+	w = register("Correct#Horse1" + strings.Repeat("x", 58))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
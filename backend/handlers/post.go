@@ -2,15 +2,34 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
 	"github.com/prem0x01/Blogy/utils"
 )
 
+// publishedPostFilter restricts a query aliasing posts as p to posts that
+// anyone may read.
+const publishedPostFilter = "p.status = 'published'"
+
+// visiblePostFilter adds the viewer's own posts, or every post when the
+// second argument is true. It takes the viewer's ID and that flag.
+const visiblePostFilter = "(" + publishedPostFilter + " OR p.user_id = ? OR ?)"
+
+var (
+	errScheduleArchived = errors.New("archived posts cannot be scheduled")
+	errVersionConflict  = errors.New("post has been modified")
+)
+
 type PostHandler struct {
 	db *sql.DB
 }
@@ -23,13 +42,19 @@ func (h *PostHandler) GetPosts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	offset := (page - 1) * pageSize
+	tag := models.NormalizeTag(c.Query("tag"))
 
-	posts, total, err := h.getPosts(pageSize, offset)
+	posts, total, err := h.getPosts(tag, pageSize, offset)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch posts")
 		return
 	}
 
+	if err := loadPostLikes(h.db, posts, c.GetInt64("user_id")); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch likes")
+		return
+	}
+
 	utils.PaginatedSuccessResponse(c, posts, total, page, pageSize)
 }
 
@@ -40,7 +65,9 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 		return
 	}
 
-	post, err := h.getPostByID(id)
+	viewer := actorFrom(c)
+
+	post, err := h.getPostByID(id, viewer)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
@@ -50,13 +77,71 @@ func (h *PostHandler) GetPost(c *gin.Context) {
 		return
 	}
 
-	comments, err := h.getPostComments(id)
+	h.respondWithPostDetails(c, post, viewer.UserID)
+}
+
+// GetPostBySlug looks a post up by its current slug. A slug the post used
+// to have answers with a permanent redirect to the current one.
+func (h *PostHandler) GetPostBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+	viewer := actorFrom(c)
+
+	post, err := h.getPostBySlug(slug, viewer)
+	if err == sql.ErrNoRows {
+		var current string
+		err = h.db.QueryRow(`
+            SELECT p.slug FROM slug_history sh
+            JOIN posts p ON p.id = sh.post_id
+            WHERE sh.slug = ? AND `+visiblePostFilter+`
+        `, slug, viewer.UserID, viewer.Can(policy.ManageAnyPost)).Scan(&current)
+		if err == sql.ErrNoRows {
+			utils.ErrorResponse(c, http.StatusNotFound, "Post not found")
+			return
+		} else if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch post")
+			return
+		}
+
+		c.Redirect(http.StatusMovedPermanently, "/api/posts/by-slug/"+url.PathEscape(current))
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch post")
+		return
+	}
+
+	h.respondWithPostDetails(c, post, viewer.UserID)
+}
+
+// respondWithPostDetails writes a single post together with its tags,
+// likes and comments.
+func (h *PostHandler) respondWithPostDetails(c *gin.Context, post *models.Post, viewerID int64) {
+	if err := loadPostTags(h.db, []*models.Post{post}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	if err := loadPostLikes(h.db, []*models.Post{post}, viewerID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch likes")
+		return
+	}
+
+	comments, err := h.getPostComments(post.ID, viewerID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch comments")
 		return
 	}
+
+	commentRefs := make([]*models.Comment, len(comments))
+	for i := range comments {
+		commentRefs[i] = &comments[i]
+	}
+	if err := loadCommentLikes(h.db, commentRefs, viewerID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch likes")
+		return
+	}
 	post.Comments = comments
 
+	c.Header("ETag", postETag(post.Version))
 	utils.SuccessResponse(c, post)
 }
 
@@ -67,16 +152,33 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
 	userID := c.GetInt64("user_id")
 
+	status := input.Status
+	if status == "" {
+		status = models.PostStatusPublished
+	}
+
 	post := &models.Post{
 		UserID:    userID,
 		Title:     input.Title,
 		Content:   input.Content,
+		Status:    status,
+		Tags:      models.NormalizeTags(input.Tags),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
+	if err := schedulePost(post, input.PublishAt); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err := h.createPost(post); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create post")
 		return
@@ -86,9 +188,8 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 }
 
 func (h *PostHandler) UpdatePost(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+	id, ok := h.managedPostParam(c)
+	if !ok {
 		return
 	}
 
@@ -98,38 +199,167 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetInt64("user_id")
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	version, err := ifMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	actor := actorFrom(c)
+
+	// Tags are left alone when the field is omitted.
+	var tags []string
+	if input.Tags != nil {
+		tags = models.NormalizeTags(input.Tags)
+	}
 
+	// Status is left alone when the field is omitted. Setting a status
+	// without a publish_at cancels any pending schedule.
 	post := &models.Post{
 		ID:        id,
-		UserID:    userID,
+		UserID:    actor.UserID,
 		Title:     input.Title,
 		Content:   input.Content,
+		Status:    input.Status,
+		Tags:      tags,
+		Version:   version,
 		UpdatedAt: time.Now(),
 	}
 
+	if err := schedulePost(post, input.PublishAt); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err := h.updatePost(post); err != nil {
-		if err == sql.ErrNoRows {
+		switch err {
+		case sql.ErrNoRows:
 			utils.ErrorResponse(c, http.StatusNotFound, "Post not found or unauthorized")
-			return
+		case errVersionConflict:
+			h.respondWithConflict(c, id, actor)
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update post")
 		}
+		return
+	}
+
+	h.respondWithPost(c, id, actor)
+}
+
+func (h *PostHandler) PublishPost(c *gin.Context) {
+	h.setStatus(c, models.PostStatusPublished)
+}
+
+func (h *PostHandler) UnpublishPost(c *gin.Context) {
+	h.setStatus(c, models.PostStatusDraft)
+}
+
+func (h *PostHandler) ArchivePost(c *gin.Context) {
+	h.setStatus(c, models.PostStatusArchived)
+}
+
+// GetMyPosts lists the caller's posts; ?status= also accepts "scheduled".
+func (h *PostHandler) GetMyPosts(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != models.PostStatusDraft && status != "scheduled" &&
+		status != models.PostStatusPublished && status != models.PostStatusArchived {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+	userID := c.GetInt64("user_id")
+
+	posts, total, err := h.getPostsByAuthor(userID, status, pageSize, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch posts")
+		return
+	}
+
+	if err := loadPostTags(h.db, posts); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, posts, total, page, pageSize)
+}
+
+func (h *PostHandler) setStatus(c *gin.Context, status string) {
+	id, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	// An explicit status change cancels any pending schedule. Publishing
+	// a post that was not live dates it from now.
+	result, err := h.db.Exec(`
+        UPDATE posts SET status = ?, publish_at = NULL, version = version + 1,
+            published_at = CASE WHEN ? = 'published' AND status <> 'published' THEN ? ELSE published_at END
+        WHERE id = ?
+    `, status, status, time.Now().UTC(), id)
+	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update post")
 		return
 	}
 
-	utils.SuccessResponse(c, post)
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Post not found or unauthorized")
+		return
+	}
+
+	h.respondWithPost(c, id, actorFrom(c))
 }
 
-func (h *PostHandler) DeletePost(c *gin.Context) {
-	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+// schedulePost holds a post as a draft until a future publishAt, or
+// publishes it now when that has passed.
+func schedulePost(post *models.Post, publishAt *time.Time) error {
+	if publishAt == nil {
+		return nil
+	}
+	if post.Status == models.PostStatusArchived {
+		return errScheduleArchived
+	}
+
+	at := publishAt.UTC()
+	post.PublishAt = &at
+	if at.After(time.Now()) {
+		post.Status = models.PostStatusDraft
+	} else {
+		post.Status = models.PostStatusPublished
+	}
+	return nil
+}
+
+// respondWithPost reloads a post after a write so the response reflects
+// what was actually stored.
+func (h *PostHandler) respondWithPost(c *gin.Context, id int64, viewer policy.Actor) {
+	post, err := h.getPostByID(id, viewer)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch post")
 		return
 	}
 
-	userID := c.GetInt64("user_id")
+	if err := loadPostTags(h.db, []*models.Post{post}); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
 
-	if err := h.deletePost(postID, userID); err != nil {
+	c.Header("ETag", postETag(post.Version))
+	utils.SuccessResponse(c, post)
+}
+
+func (h *PostHandler) DeletePost(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.deletePost(postID); err != nil {
 		if err == sql.ErrNoRows {
 			utils.ErrorResponse(c, http.StatusNotFound, "Post not found or unauthorized")
 			return
@@ -141,22 +371,56 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 	utils.SuccessResponse(c, gin.H{"message": "Post deleted successfully"})
 }
 
-func (h *PostHandler) getPosts(limit, offset int) ([]*models.Post, int64, error) {
+// managedPostParam parses :id and answers 404 unless the caller may manage
+// the post.
+func (h *PostHandler) managedPostParam(c *gin.Context) (int64, bool) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid post ID")
+		return 0, false
+	}
+
+	var ownerID int64
+	err = h.db.QueryRow("SELECT user_id FROM posts WHERE id = ?", postID).Scan(&ownerID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return 0, false
+	}
+	if err == sql.ErrNoRows || !policy.CanManagePost(actorFrom(c), ownerID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Post not found or unauthorized")
+		return 0, false
+	}
+
+	return postID, true
+}
+
+func (h *PostHandler) getPosts(tag string, limit, offset int) ([]*models.Post, int64, error) {
+	where := publishedPostFilter
+	args := []interface{}{}
+	if tag != "" {
+		where += ` AND p.id IN (
+			SELECT pt.post_id FROM post_tags pt
+			JOIN tags t ON t.id = pt.tag_id
+			WHERE t.name = ?
+		)`
+		args = append(args, tag)
+	}
+
 	var total int64
-	err := h.db.QueryRow("SELECT COUNT(*) FROM posts WHERE status = 'published'").Scan(&total)
+	err := h.db.QueryRow("SELECT COUNT(*) FROM posts p WHERE "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	rows, err := h.db.Query(`
-        SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at,
-               u.username, u.email
+        SELECT p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.slug, p.status, p.published_at,
+               p.created_at, p.updated_at, u.username, u.email
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.status = 'published'
-        ORDER BY p.created_at DESC
+        WHERE `+where+`
+        ORDER BY p.published_at DESC, p.id DESC
         LIMIT ? OFFSET ?
-    `, limit, offset)
+    `, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -170,6 +434,10 @@ func (h *PostHandler) getPosts(limit, offset int) ([]*models.Post, int64, error)
 			&post.UserID,
 			&post.Title,
 			&post.Content,
+			&post.ContentHTML,
+			&post.Slug,
+			&post.Status,
+			&post.PublishedAt,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 			&post.Author.Username,
@@ -181,22 +449,45 @@ func (h *PostHandler) getPosts(limit, offset int) ([]*models.Post, int64, error)
 		posts = append(posts, post)
 	}
 
+	if err := loadPostTags(h.db, posts); err != nil {
+		return nil, 0, err
+	}
+
 	return posts, total, nil
 }
 
-func (h *PostHandler) getPostByID(id int64) (*models.Post, error) {
+// getPostByID returns a post if viewer may read it, following
+// visiblePostFilter.
+func (h *PostHandler) getPostByID(id int64, viewer policy.Actor) (*models.Post, error) {
+	return h.getPostWhere("p.id = ?", id, viewer)
+}
+
+// getPostBySlug is getPostByID keyed on the current slug.
+func (h *PostHandler) getPostBySlug(slug string, viewer policy.Actor) (*models.Post, error) {
+	return h.getPostWhere("p.slug = ?", slug, viewer)
+}
+
+func (h *PostHandler) getPostWhere(condition string, key interface{}, viewer policy.Actor) (*models.Post, error) {
 	post := &models.Post{Author: &models.User{}}
 	err := h.db.QueryRow(`
-        SELECT p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at,
-               u.username, u.email
+        SELECT p.id, p.user_id, p.title, p.content, COALESCE(p.content_html, ''), p.slug, p.status, p.publish_at, p.published_at, p.version,
+               COALESCE(p.comment_mode, ''), p.comments_locked, p.created_at, p.updated_at, u.username, u.email
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.id = ? AND p.status = 'published'
-    `, id).Scan(
+        WHERE `+condition+` AND `+visiblePostFilter+`
+    `, key, viewer.UserID, viewer.Can(policy.ManageAnyPost)).Scan(
 		&post.ID,
 		&post.UserID,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
+		&post.Slug,
+		&post.Status,
+		&post.PublishAt,
+		&post.PublishedAt,
+		&post.Version,
+		&post.CommentMode,
+		&post.CommentsLocked,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Author.Username,
@@ -205,15 +496,79 @@ func (h *PostHandler) getPostByID(id int64) (*models.Post, error) {
 	return post, err
 }
 
-func (h *PostHandler) getPostComments(postID int64) ([]models.Comment, error) {
+func (h *PostHandler) getPostsByAuthor(userID int64, status string, limit, offset int) ([]*models.Post, int64, error) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	switch status {
+	case "":
+	case "scheduled":
+		where += " AND status = 'draft' AND publish_at IS NOT NULL"
+	default:
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+
+	var total int64
+	err := h.db.QueryRow("SELECT COUNT(*) FROM posts WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, user_id, title, content, COALESCE(content_html, ''), slug, status, publish_at, created_at, updated_at
+        FROM posts
+        WHERE `+where+`
+        ORDER BY updated_at DESC, id DESC
+        LIMIT ? OFFSET ?
+    `, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	posts := []*models.Post{}
+	for rows.Next() {
+		post := &models.Post{}
+		err := rows.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Title,
+			&post.Content,
+			&post.ContentHTML,
+			&post.Slug,
+			&post.Status,
+			&post.PublishAt,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, total, rows.Err()
+}
+
+// postVisibleTo reports whether a post exists and viewer may read it.
+func postVisibleTo(db *sql.DB, postID int64, viewer policy.Actor) (bool, error) {
+	var visible bool
+	err := db.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM posts p
+            WHERE p.id = ? AND `+visiblePostFilter+`
+        )
+    `, postID, viewer.UserID, viewer.Can(policy.ManageAnyPost)).Scan(&visible)
+	return visible, err
+}
+
+func (h *PostHandler) getPostComments(postID, viewerID int64) ([]models.Comment, error) {
 	rows, err := h.db.Query(`
-        SELECT c.id, c.post_id, c.user_id, c.content, c.created_at,
-               u.username, u.email
+        SELECT `+commentColumns+`
         FROM comments c
         JOIN users u ON c.user_id = u.id
-        WHERE c.post_id = ?
+        WHERE c.post_id = ? AND `+visibleCommentFilter+`
         ORDER BY c.created_at DESC
-    `, postID)
+    `, postID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -221,30 +576,46 @@ func (h *PostHandler) getPostComments(postID int64) ([]models.Comment, error) {
 
 	var comments []models.Comment
 	for rows.Next() {
-		var comment models.Comment
-		comment.Author = &models.User{}
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.Author.Username,
-			&comment.Author.Email,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, err
 		}
-		comments = append(comments, comment)
+		comments = append(comments, *comment)
 	}
 	return comments, nil
 }
 
 func (h *PostHandler) createPost(post *models.Post) error {
-	result, err := h.db.Exec(`
-        INSERT INTO posts (user_id, title, content, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?)
-    `, post.UserID, post.Title, post.Content, post.CreatedAt, post.UpdatedAt)
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	slug, err := uniqueSlug(tx, utils.Slugify(post.Title), 0)
+	if err != nil {
+		return err
+	}
+
+	if post.ContentHTML, err = utils.RenderMarkdown(post.Content); err != nil {
+		return err
+	}
+
+	// A post published on creation counts from its schedule, which may
+	// lie in the past, or else from now.
+	var publishedAt *time.Time
+	if post.Status == models.PostStatusPublished {
+		at := post.CreatedAt.UTC()
+		if post.PublishAt != nil {
+			at = *post.PublishAt
+		}
+		publishedAt = &at
+	}
+
+	result, err := tx.Exec(`
+        INSERT INTO posts (user_id, title, content, content_html, slug, status, publish_at, published_at, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, post.UserID, post.Title, post.Content, post.ContentHTML, slug, post.Status, post.PublishAt, publishedAt, post.CreatedAt, post.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -254,37 +625,161 @@ func (h *PostHandler) createPost(post *models.Post) error {
 		return err
 	}
 
+	if err := setPostTags(tx, id, post.Tags); err != nil {
+		return err
+	}
+
+	if err := recordRevision(tx, id, post.UserID, post.Title, post.Content); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	post.ID = id
+	post.Slug = slug
 	return nil
 }
 
+// updatePost saves an edit by post.UserID, who the caller has already
+// checked may manage the post; the post keeps its original author.
 func (h *PostHandler) updatePost(post *models.Post) error {
-	result, err := h.db.Exec(`
-        UPDATE posts 
-        SET title = ?, content = ?, updated_at = ?
-        WHERE id = ? AND user_id = ?
-    `, post.Title, post.Content, post.UpdatedAt, post.ID, post.UserID)
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldTitle, oldContent, oldSlug string
+	var oldVersion int64
+	err = tx.QueryRow(
+		"SELECT title, content, slug, version FROM posts WHERE id = ?",
+		post.ID,
+	).Scan(&oldTitle, &oldContent, &oldSlug, &oldVersion)
 	if err != nil {
 		return err
 	}
 
-	affected, err := result.RowsAffected()
+	// A zero Version means the caller did not ask for a conditional update.
+	if post.Version != 0 && post.Version != oldVersion {
+		return errVersionConflict
+	}
+
+	slug := oldSlug
+	if base := utils.Slugify(post.Title); base != utils.Slugify(oldTitle) {
+		if slug, err = uniqueSlug(tx, base, post.ID); err != nil {
+			return err
+		}
+	}
+
+	if post.ContentHTML, err = utils.RenderMarkdown(post.Content); err != nil {
+		return err
+	}
+
+	// Matching on the version read above keeps a concurrent writer that
+	// committed in between from being overwritten.
+	result, err := tx.Exec(`
+        UPDATE posts 
+        SET title = ?, content = ?, content_html = ?, slug = ?, updated_at = ?,
+            status = COALESCE(NULLIF(?, ''), status),
+            publish_at = CASE WHEN ? = '' THEN publish_at ELSE ? END,
+            published_at = CASE WHEN ? = 'published' AND status <> 'published' THEN COALESCE(?, ?) ELSE published_at END,
+            version = version + 1
+        WHERE id = ? AND version = ?
+    `, post.Title, post.Content, post.ContentHTML, slug, post.UpdatedAt, post.Status, post.Status, post.PublishAt,
+		post.Status, post.PublishAt, post.UpdatedAt.UTC(), post.ID, oldVersion)
 	if err != nil {
 		return err
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return errVersionConflict
+	}
 
-	if affected == 0 {
-		return sql.ErrNoRows
+	if slug != oldSlug {
+		if err := recordSlugChange(tx, post.ID, oldSlug, slug); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// Saves that only touch status, schedule or tags are not revisions.
+	if post.Title != oldTitle || post.Content != oldContent {
+		if err := recordRevision(tx, post.ID, post.UserID, post.Title, post.Content); err != nil {
+			return err
+		}
+	}
+
+	if post.Tags != nil {
+		if err := setPostTags(tx, post.ID, post.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// uniqueSlug returns base, or base with the first free numeric suffix.
+// Retired slugs stay reserved for their redirects.
+func uniqueSlug(tx *sql.Tx, base string, excludePostID int64) (string, error) {
+	// Every numbered form of base starts with stem, however long its
+	// suffix, so a single query finds all the slugs it could run into.
+	stem := base
+	if max := utils.MaxSlugLength - len(fmt.Sprintf("-%d", math.MaxInt)); len(stem) > max {
+		stem = strings.TrimRight(stem[:max], "-")
+	}
+	rows, err := tx.Query(`
+        SELECT slug FROM posts WHERE slug LIKE ? || '%' AND id != ?
+        UNION
+        SELECT slug FROM slug_history WHERE slug LIKE ? || '%' AND post_id != ?
+    `, stem, excludePostID, stem, excludePostID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", err
+		}
+		taken[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	candidate := base
+	for n := 2; taken[candidate]; n++ {
+		// Slugs are ASCII, so cutting bytes off the base keeps the
+		// numbered slug within the length limit.
+		suffix := fmt.Sprintf("-%d", n)
+		if max := utils.MaxSlugLength - len(suffix); len(base) > max {
+			base = strings.TrimRight(base[:max], "-")
+		}
+		candidate = base + suffix
+	}
+	return candidate, nil
 }
 
-func (h *PostHandler) deletePost(postID, userID int64) error {
+func recordSlugChange(tx *sql.Tx, postID int64, oldSlug, newSlug string) error {
+	if _, err := tx.Exec(
+		"INSERT OR REPLACE INTO slug_history (slug, post_id) VALUES (?, ?)",
+		oldSlug, postID,
+	); err != nil {
+		return err
+	}
+
+	// A post going back to a slug it used before takes it out of history.
+	_, err := tx.Exec("DELETE FROM slug_history WHERE slug = ?", newSlug)
+	return err
+}
+
+func (h *PostHandler) deletePost(postID int64) error {
 	result, err := h.db.Exec(`
         DELETE FROM posts 
-        WHERE id = ? AND user_id = ?
-    `, postID, userID)
+        WHERE id = ?
+    `, postID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type postPage struct {
+	Items      []models.Post `json:"items"`
+	TotalItems int64         `json:"total_items"`
+}
+
+func TestPostStatusWorkflow(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	otherID := insertTestUser(t, db, "other")
+	postID := insertTestPost(t, db, authorID, "wip", "Work in progress", "Some content here.")
+	_, err := db.Exec("UPDATE posts SET status = 'draft' WHERE id = ?", postID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	router.GET("/posts", posts.GetPosts)
+	router.GET("/posts/:id", posts.GetPost)
+	author := router.Group("/author", withUser(authorID))
+	author.GET("/posts/:id", posts.GetPost)
+	author.POST("/posts/:id/publish", posts.PublishPost)
+	author.POST("/posts/:id/archive", posts.ArchivePost)
+	author.GET("/me/posts", posts.GetMyPosts)
+	other := router.Group("/other", withUser(otherID))
+	other.GET("/posts/:id", posts.GetPost)
+	other.POST("/posts/:id/publish", posts.PublishPost)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/posts/1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/other/posts/1", nil).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "GET", "/author/posts/1", nil).Code)
+
+	var page postPage
+	w := doJSON(router, "GET", "/author/me/posts?status=draft", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	require.Equal(t, int64(1), page.TotalItems)
+	assert.Equal(t, models.PostStatusDraft, page.Items[0].Status)
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "GET", "/author/me/posts?status=bogus", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "POST", "/other/posts/1/publish", nil).Code)
+
+	var post models.Post
+	w = doJSON(router, "POST", "/author/posts/1/publish", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Equal(t, models.PostStatusPublished, post.Status)
+
+	w = doJSON(router, "GET", "/posts", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	assert.Equal(t, int64(1), page.TotalItems)
+
+	w = doJSON(router, "POST", "/author/posts/1/archive", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/posts/1", nil).Code)
+}
+
+func TestSchedulePostOnUpdate(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	insertTestPost(t, db, authorID, "soon", "Coming soon", "Some content here.")
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	router.GET("/posts/:id", posts.GetPost)
+	author := router.Group("/author", withUser(authorID))
+	author.PUT("/posts/:id", posts.UpdatePost)
+	author.GET("/me/posts", posts.GetMyPosts)
+
+	publishAt := time.Now().Add(time.Hour)
+	w := doJSON(router, "PUT", "/author/posts/1", models.PostInput{
+		Title:     "Coming soon",
+		Content:   "Some content here.",
+		PublishAt: &publishAt,
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var post models.Post
+	decodeData(t, w, &post)
+	assert.Equal(t, models.PostStatusDraft, post.Status)
+	require.NotNil(t, post.PublishAt)
+	assert.WithinDuration(t, publishAt, *post.PublishAt, time.Second)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/posts/1", nil).Code)
+
+	var page postPage
+	w = doJSON(router, "GET", "/author/me/posts?status=scheduled", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	assert.Equal(t, int64(1), page.TotalItems)
+
+	w = doJSON(router, "PUT", "/author/posts/1", models.PostInput{
+		Title:     "Coming soon",
+		Content:   "Some content here.",
+		Status:    models.PostStatusArchived,
+		PublishAt: &publishAt,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPostSlugs(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	router.GET("/api/posts/:id", posts.GetPost)
+	router.GET("/api/posts/by-slug/:slug", posts.GetPostBySlug)
+	author := router.Group("/author", withUser(authorID))
+	author.POST("/posts", posts.CreatePost)
+	author.PUT("/posts/:id", posts.UpdatePost)
+
+	create := func(title string) models.Post {
+		w := doJSON(router, "POST", "/author/posts", models.PostInput{Title: title, Content: "Some content here."})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var post models.Post
+		decodeData(t, w, &post)
+		return post
+	}
+
+	first := create("Café au lait")
+	second := create("Cafe au Lait!")
+	assert.Equal(t, "cafe-au-lait", first.Slug)
+	assert.Equal(t, "cafe-au-lait-2", second.Slug)
+
+	w := doJSON(router, "PUT", fmt.Sprintf("/author/posts/%d", first.ID), models.PostInput{
+		Title:   "Flat white",
+		Content: "Some content here.",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var renamed models.Post
+	decodeData(t, w, &renamed)
+	assert.Equal(t, "flat-white", renamed.Slug)
+
+	// Editing the body alone keeps the slug.
+	w = doJSON(router, "PUT", fmt.Sprintf("/author/posts/%d", first.ID), models.PostInput{
+		Title:   "Flat White",
+		Content: "Other content here.",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &renamed)
+	assert.Equal(t, "flat-white", renamed.Slug)
+
+	w = doJSON(router, "GET", "/api/posts/by-slug/flat-white", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(router, "GET", "/api/posts/by-slug/cafe-au-lait", nil)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/api/posts/by-slug/flat-white", w.Header().Get("Location"))
+
+	// The old slug stays reserved for its redirect.
+	third := create("Café au lait")
+	assert.Equal(t, "cafe-au-lait-3", third.Slug)
+
+	w = doJSON(router, "GET", "/api/posts/by-slug/nope", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Numbered slugs of long titles stay within the length limit.
+	long := strings.Repeat("x", utils.MaxSlugLength+20)
+	assert.Equal(t, strings.Repeat("x", utils.MaxSlugLength), create(long).Slug)
+	assert.Equal(t, strings.Repeat("x", utils.MaxSlugLength-2)+"-2", create(long).Slug)
+}
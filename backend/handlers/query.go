@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// execer is what *sql.DB and *sql.Tx have in common for writes.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// placeholders returns "?, ?, ..." with n markers for an IN clause.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+// escapeLike escapes LIKE wildcards; use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleBasedAccess(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	readerID := insertTestUser(t, db, "reader")
+	moderatorID := insertTestUser(t, db, "moderator")
+	adminID := insertTestUser(t, db, "admin")
+	otherID := insertTestUser(t, db, "other")
+
+	insertTestPost(t, db, authorID, "published", "Published", "Open to all.")
+	draftID := insertTestPost(t, db, authorID, "draft", "Draft", "Not yet.")
+	_, err := db.Exec("UPDATE posts SET status = 'draft' WHERE id = ?", draftID)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO comments (post_id, user_id, content, status) VALUES
+			(1, ?, 'abusive comment', 'approved'),
+			(1, ?, 'held comment', 'pending')
+	`, readerID, readerID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+	comments := NewCommentHandler(db.DB, CommentOptions{TreeDepth: 1, RepliesPerBranch: 5})
+	moderation := NewModerationHandler(db.DB, nil)
+
+	router := gin.New()
+	actors := map[string]gin.HandlerFunc{
+		"reader":    withActor(readerID, models.RoleUser),
+		"other":     withActor(otherID, models.RoleAuthor),
+		"moderator": withActor(moderatorID, models.RoleModerator),
+		"admin":     withActor(adminID, models.RoleAdmin),
+	}
+	for name, actor := range actors {
+		group := router.Group("/"+name, actor)
+		group.POST("/posts", middleware.RequirePermission(policy.CreatePosts), posts.CreatePost)
+		group.GET("/posts/:id", posts.GetPost)
+		group.PUT("/posts/:id", posts.UpdatePost)
+		group.DELETE("/comments/:id", comments.DeleteComment)
+		group.GET("/comments/:id/history", comments.GetCommentHistory)
+		group.GET("/moderation/comments", moderation.GetQueue)
+		group.POST("/moderation/comments/:id/approve", moderation.ApproveComment)
+	}
+
+	// Readers may comment but not write posts.
+	newPost := gin.H{"title": "Mine", "content": "Some content here."}
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "POST", "/reader/posts", newPost).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "POST", "/other/posts", newPost).Code)
+
+	// Only the owner and admins see or edit someone else's draft.
+	edit := gin.H{"title": "Draft", "content": "Fixed by an admin."}
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/other/posts/2", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "PUT", "/other/posts/2", edit).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "PUT", "/moderator/posts/2", edit).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "GET", "/admin/posts/2", nil).Code)
+
+	w := doJSON(router, "PUT", "/admin/posts/2", edit)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var post models.Post
+	decodeData(t, w, &post)
+	assert.Equal(t, "Fixed by an admin.", post.Content)
+	assert.Equal(t, authorID, post.UserID)
+
+	// Moderators work the queue of every post and remove any comment.
+	var queue commentPage
+	decodeData(t, doJSON(router, "GET", "/moderator/moderation/comments", nil), &queue)
+	assert.Equal(t, int64(1), queue.TotalItems)
+	decodeData(t, doJSON(router, "GET", "/other/moderation/comments", nil), &queue)
+	assert.Equal(t, int64(0), queue.TotalItems)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "POST", "/other/moderation/comments/2/approve", nil).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "POST", "/moderator/moderation/comments/2/approve", nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "GET", "/other/comments/1/history", nil).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "GET", "/moderator/comments/1/history", nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, doJSON(router, "DELETE", "/other/comments/1", nil).Code)
+	assert.Equal(t, http.StatusOK, doJSON(router, "DELETE", "/moderator/comments/1", nil).Code)
+}
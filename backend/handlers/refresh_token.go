@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	errRefreshTokenInvalid = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// refreshToken is a stored refresh token; only its hash is kept.
+type refreshToken struct {
+	id        int64
+	userID    int64
+	familyID  string
+	sessionID int64
+	expiresAt time.Time
+	usedAt    sql.NullTime
+	revokedAt sql.NullTime
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshToken stores a new token in familyID and returns it.
+func issueRefreshToken(exec execer, userID int64, familyID string) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	_, err = exec.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, familyID, hashRefreshToken(token), now, now.Add(refreshTokenTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (h *AuthHandler) getRefreshToken(token string) (*refreshToken, error) {
+	rt := &refreshToken{}
+	err := h.db.QueryRow(`
+		SELECT rt.id, rt.user_id, rt.family_id, COALESCE(s.id, 0), rt.expires_at, rt.used_at, rt.revoked_at
+		FROM refresh_tokens rt
+		LEFT JOIN sessions s ON s.family_id = rt.family_id
+		WHERE rt.token_hash = ?
+	`, hashRefreshToken(token)).Scan(&rt.id, &rt.userID, &rt.familyID, &rt.sessionID, &rt.expiresAt, &rt.usedAt, &rt.revokedAt)
+	return rt, err
+}
+
+// checkRefreshToken makes sure token can be spent. Reusing a spent token
+// revokes its whole family.
+func (h *AuthHandler) checkRefreshToken(token string) (*refreshToken, error) {
+	rt, err := h.getRefreshToken(token)
+	if err == sql.ErrNoRows {
+		return nil, errRefreshTokenInvalid
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case rt.revokedAt.Valid:
+		return nil, errRefreshTokenInvalid
+	case rt.usedAt.Valid:
+		if err := h.revokeSession(rt.userID, rt.familyID); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	case !rt.expiresAt.After(time.Now()):
+		return nil, errRefreshTokenInvalid
+	}
+	return rt, nil
+}
+
+// rotateRefreshToken spends rt and issues its successor in the same family.
+func (h *AuthHandler) rotateRefreshToken(rt *refreshToken, userAgent, ip string) (string, error) {
+	now := time.Now().UTC()
+	tx, err := h.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens SET used_at = ?
+		WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL
+	`, now, rt.id)
+	if err != nil {
+		return "", err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		if err := revokeTokenFamily(tx, rt.familyID); err != nil {
+			return "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		h.forget(rt.userID)
+		return "", errRefreshTokenReused
+	}
+
+	next, err := issueRefreshToken(tx, rt.userID, rt.familyID)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE sessions SET last_seen_at = ?, user_agent = ?, ip_address = ?
+		WHERE family_id = ?
+	`, now, userAgent, ip, rt.familyID); err != nil {
+		return "", err
+	}
+
+	return next, tx.Commit()
+}
+
+// revokeSession ends the session of a token family.
+func (h *AuthHandler) revokeSession(userID int64, familyID string) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeTokenFamily(tx, familyID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	h.forget(userID)
+	return nil
+}
+
+func revokeTokenFamily(tx *sql.Tx, familyID string) error {
+	now := time.Now().UTC()
+	if _, err := tx.Exec(
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+		now, familyID,
+	); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+		now, familyID,
+	)
+	return err
+}
+
+// revokeAllTokens ends every session of userID and reports whether the
+// user exists.
+func revokeAllTokens(tx *sql.Tx, userID int64) (bool, error) {
+	// Token times only have whole seconds, so the revocation is stored the
+	// same way and a token issued later in the same second stays valid.
+	now := time.Now().UTC()
+	result, err := tx.Exec("UPDATE users SET tokens_revoked_at = ? WHERE id = ?", now.Truncate(time.Second), userID)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now, userID,
+	); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now, userID,
+	)
+	return err == nil, err
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	keys := signing.NewHMAC("test-secret")
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "alice")
+
+	gin.SetMode(gin.TestMode)
+	authenticator := middleware.NewAuthenticator(keys, db.DB, time.Minute)
+	auth := NewAuthHandler(db.DB, AuthOptions{Keys: keys, Sessions: authenticator})
+	router := gin.New()
+	router.POST("/refresh", auth.RefreshToken)
+	router.POST("/logout", auth.Logout)
+	router.POST("/logout-all", middleware.AuthMiddleware(authenticator), auth.LogoutAll)
+
+	type tokens struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	refresh := func(token string) (int, tokens) {
+		t.Helper()
+		var pair tokens
+		w := doJSON(router, "POST", "/refresh", gin.H{"refreshToken": token})
+		if w.Code == http.StatusOK {
+			decodeData(t, w, &pair)
+		}
+		return w.Code, pair
+	}
+	signIn := func() tokens {
+		t.Helper()
+		access, refresh, err := auth.generateTokenPair(userID, models.RoleAuthor, "test-agent", "127.0.0.1")
+		require.NoError(t, err)
+		return tokens{access, refresh}
+	}
+
+	// Each refresh token works once and hands out its successor.
+	first := signIn()
+	code, second := refresh(first.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	code, third := refresh(second.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// Replaying a spent token revokes the whole family, including the
+	// token the legitimate client holds now.
+	code, _ = refresh(first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = refresh(third.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = refresh("not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Logging out ends one session and leaves the others alone.
+	laptop, phone := signIn(), signIn()
+	require.Equal(t, http.StatusOK, doJSON(router, "POST", "/logout", gin.H{"refreshToken": laptop.RefreshToken}).Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "POST", "/logout", gin.H{"refreshToken": laptop.RefreshToken}).Code)
+	code, _ = refresh(laptop.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, phone = refresh(phone.RefreshToken)
+	require.Equal(t, http.StatusOK, code)
+
+	// Logging out everywhere ends every session, access tokens included.
+	tablet := signIn()
+	require.Equal(t, http.StatusOK, doJSONWithToken(router, tablet.AccessToken, "POST", "/logout-all", nil).Code)
+	code, _ = refresh(phone.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = refresh(tablet.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, http.StatusUnauthorized, doJSONWithToken(router, phone.AccessToken, "POST", "/logout-all", nil).Code)
+}
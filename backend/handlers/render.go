@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// RenderPreview renders Markdown exactly as it would be stored on save,
+// without saving anything.
+func (h *PostHandler) RenderPreview(c *gin.Context) {
+	var input models.RenderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	html, err := utils.RenderMarkdown(input.Content)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render content")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"content_html": html})
+}
+
+// RenderPendingPosts fills in content_html for posts saved before content
+// was rendered server-side, and returns how many it rendered.
+func RenderPendingPosts(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT id, content FROM posts WHERE content_html IS NULL")
+	if err != nil {
+		return 0, err
+	}
+
+	pending := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending[id] = content
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, content := range pending {
+		html, err := utils.RenderMarkdown(content)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := db.Exec("UPDATE posts SET content_html = ? WHERE id = ?", html, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostContentHTML(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+
+	// Posts saved before content was rendered are filled in at startup.
+	insertTestPost(t, db, authorID, "legacy", "Legacy post", "Some *old* content.")
+	rendered, err := RenderPendingPosts(db.DB)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rendered)
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	author := router.Group("/author", withUser(authorID))
+	author.GET("/posts/:id", posts.GetPost)
+	author.POST("/posts", posts.CreatePost)
+	author.PUT("/posts/:id", posts.UpdatePost)
+	author.POST("/render", posts.RenderPreview)
+
+	var post models.Post
+	w := doJSON(router, "GET", "/author/posts/1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Equal(t, "<p>Some <em>old</em> content.</p>\n", post.ContentHTML)
+
+	w = doJSON(router, "POST", "/author/posts", models.PostInput{
+		Title:   "Rendered",
+		Content: "# Title\n\n<script>alert(1)</script>",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Contains(t, post.ContentHTML, `<h1 id="title">Title</h1>`)
+	assert.NotContains(t, post.ContentHTML, "<script")
+
+	w = doJSON(router, "PUT", "/author/posts/2", models.PostInput{
+		Title:   "Rendered",
+		Content: "Now with **bold** text.",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Equal(t, "<p>Now with <strong>bold</strong> text.</p>\n", post.ContentHTML)
+
+	// Posts are capped at the same length as previews.
+	w = doJSON(router, "PUT", "/author/posts/2", models.PostInput{
+		Title:   "Rendered",
+		Content: strings.Repeat("a", 100001),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var preview struct {
+		ContentHTML string `json:"content_html"`
+	}
+	w = doJSON(router, "POST", "/author/render", models.RenderInput{Content: "[x](javascript:alert(1))"})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &preview)
+	assert.Equal(t, "<p>x</p>\n", preview.ContentHTML)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// Revision endpoints answer 404 to anyone who may not manage the post.
+
+func (h *PostHandler) GetRevisions(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, post_id, revision, user_id, title, created_at
+        FROM post_revisions
+        WHERE post_id = ?
+        ORDER BY revision DESC
+    `, postID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch revisions")
+		return
+	}
+	defer rows.Close()
+
+	revisions := []*models.PostRevision{}
+	for rows.Next() {
+		rev := &models.PostRevision{}
+		if err := rows.Scan(&rev.ID, &rev.PostID, &rev.Revision, &rev.UserID, &rev.Title, &rev.CreatedAt); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch revisions")
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+
+	utils.SuccessResponse(c, revisions)
+}
+
+func (h *PostHandler) GetRevision(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	rev, ok := h.revisionParam(c, postID, "rev")
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, rev)
+}
+
+// DiffRevisions diffs the content of revision :rev against ?from=, which
+// defaults to the revision just before it.
+func (h *PostHandler) DiffRevisions(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	to, ok := h.revisionParam(c, postID, "rev")
+	if !ok {
+		return
+	}
+
+	from := &models.PostRevision{Revision: to.Revision - 1}
+	if c.Query("from") != "" {
+		if from, ok = h.revisionParam(c, postID, "from"); !ok {
+			return
+		}
+	} else if to.Revision > 1 {
+		var err error
+		if from, err = h.getRevision(postID, to.Revision-1); err != nil && err != sql.ErrNoRows {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch revision")
+			return
+		} else if err == sql.ErrNoRows {
+			from = &models.PostRevision{Revision: to.Revision - 1}
+		}
+	}
+
+	utils.SuccessResponse(c, models.RevisionDiff{
+		PostID:    postID,
+		From:      from.Revision,
+		To:        to.Revision,
+		FromTitle: from.Title,
+		ToTitle:   to.Title,
+		Diff: utils.UnifiedDiff(
+			from.Content,
+			to.Content,
+			fmt.Sprintf("revision %d", from.Revision),
+			fmt.Sprintf("revision %d", to.Revision),
+		),
+	})
+}
+
+// RestoreRevision saves an old revision as the current version.
+func (h *PostHandler) RestoreRevision(c *gin.Context) {
+	postID, ok := h.managedPostParam(c)
+	if !ok {
+		return
+	}
+
+	rev, ok := h.revisionParam(c, postID, "rev")
+	if !ok {
+		return
+	}
+
+	version, err := ifMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid If-Match header")
+		return
+	}
+
+	actor := actorFrom(c)
+	post := &models.Post{
+		ID:        postID,
+		UserID:    actor.UserID,
+		Title:     rev.Title,
+		Content:   rev.Content,
+		Version:   version,
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.updatePost(post); err != nil {
+		if err == errVersionConflict {
+			h.respondWithConflict(c, postID, actor)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore revision")
+		return
+	}
+
+	h.respondWithPost(c, postID, actor)
+}
+
+// revisionParam loads the revision numbered by the named path or query
+// parameter, writing the error response itself when it returns false.
+func (h *PostHandler) revisionParam(c *gin.Context, postID int64, name string) (*models.PostRevision, bool) {
+	value := c.Param(name)
+	if value == "" {
+		value = c.Query(name)
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid revision")
+		return nil, false
+	}
+
+	rev, err := h.getRevision(postID, number)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "Revision not found")
+		return nil, false
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch revision")
+		return nil, false
+	}
+
+	return rev, true
+}
+
+func (h *PostHandler) getRevision(postID int64, number int) (*models.PostRevision, error) {
+	rev := &models.PostRevision{}
+	err := h.db.QueryRow(`
+        SELECT id, post_id, revision, user_id, title, content, created_at
+        FROM post_revisions
+        WHERE post_id = ? AND revision = ?
+    `, postID, number).Scan(
+		&rev.ID,
+		&rev.PostID,
+		&rev.Revision,
+		&rev.UserID,
+		&rev.Title,
+		&rev.Content,
+		&rev.CreatedAt,
+	)
+	return rev, err
+}
+
+func recordRevision(tx *sql.Tx, postID, userID int64, title, content string) error {
+	_, err := tx.Exec(`
+        INSERT INTO post_revisions (post_id, revision, user_id, title, content, created_at)
+        SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?
+        FROM post_revisions WHERE post_id = ?
+    `, postID, userID, title, content, time.Now(), postID)
+	return err
+}
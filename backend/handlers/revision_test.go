@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostRevisions(t *testing.T) {
+	db := newTestDatabase(t)
+	authorID := insertTestUser(t, db, "author")
+	otherID := insertTestUser(t, db, "other")
+
+	gin.SetMode(gin.TestMode)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	author := router.Group("/author", withUser(authorID))
+	author.POST("/posts", posts.CreatePost)
+	author.PUT("/posts/:id", posts.UpdatePost)
+	author.POST("/posts/:id/publish", posts.PublishPost)
+	author.GET("/posts/:id/revisions", posts.GetRevisions)
+	author.GET("/posts/:id/revisions/:rev", posts.GetRevision)
+	author.GET("/posts/:id/revisions/:rev/diff", posts.DiffRevisions)
+	author.POST("/posts/:id/revisions/:rev/restore", posts.RestoreRevision)
+	other := router.Group("/other", withUser(otherID))
+	other.GET("/posts/:id/revisions", posts.GetRevisions)
+	other.POST("/posts/:id/revisions/:rev/restore", posts.RestoreRevision)
+
+	var post models.Post
+	w := doJSON(router, "POST", "/author/posts", models.PostInput{
+		Title:   "Revisions",
+		Content: "line one\nline two\nline three",
+		Status:  models.PostStatusDraft,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+
+	w = doJSON(router, "PUT", "/author/posts/1", models.PostInput{
+		Title:   "Revisions",
+		Content: "line one\nline 2\nline three",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Publishing does not touch title or content, so it is not a revision.
+	require.Equal(t, http.StatusOK, doJSON(router, "POST", "/author/posts/1/publish", nil).Code)
+
+	var revisions []models.PostRevision
+	w = doJSON(router, "GET", "/author/posts/1/revisions", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &revisions)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Empty(t, revisions[0].Content)
+
+	var diff models.RevisionDiff
+	w = doJSON(router, "GET", "/author/posts/1/revisions/2/diff", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &diff)
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, "--- revision 1\n+++ revision 2\n@@ -1,3 +1,3 @@\n line one\n-line two\n+line 2\n line three\n", diff.Diff)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/other/posts/1/revisions", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "POST", "/other/posts/1/revisions/1/restore", nil).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, "GET", "/author/posts/1/revisions/9", nil).Code)
+
+	w = doJSON(router, "POST", "/author/posts/1/revisions/1/restore", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &post)
+	assert.Equal(t, "line one\nline two\nline three", post.Content)
+	assert.Equal(t, models.PostStatusPublished, post.Status)
+
+	var rev models.PostRevision
+	w = doJSON(router, "GET", "/author/posts/1/revisions/3", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &rev)
+	assert.Equal(t, "line one\nline two\nline three", rev.Content)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"html"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+const (
+	maxSearchTerms = 10
+
+	// Private-use runes mark matches inside highlight() and snippet() so
+	// the surrounding text can be HTML-escaped before <mark> tags go in.
+	matchStart = "\uE000"
+	matchEnd   = "\uE001"
+)
+
+type SearchHandler struct {
+	db      *sql.DB
+	enabled bool
+}
+
+func NewSearchHandler(db *sql.DB, enabled bool) *SearchHandler {
+	return &SearchHandler{db: db, enabled: enabled}
+}
+
+func (h *SearchHandler) Search(c *gin.Context) {
+	if !h.enabled {
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, "Search is not available")
+		return
+	}
+
+	query := buildMatchQuery(c.Query("q"))
+	if query == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+
+	results, total, err := h.search(query, pageSize, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search posts")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, results, total, page, pageSize)
+}
+
+// buildMatchQuery quotes each term of input as an FTS5 prefix query, so
+// user input cannot inject operators.
+func buildMatchQuery(input string) string {
+	terms := strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func markMatches(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, matchStart, "<mark>")
+	return strings.ReplaceAll(s, matchEnd, "</mark>")
+}
+
+func (h *SearchHandler) search(query string, limit, offset int) ([]*models.SearchResult, int64, error) {
+	var total int64
+	err := h.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		WHERE posts_fts MATCH ? AND `+publishedPostFilter,
+		query,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// bm25 weights: title, content, tags, author.
+	rows, err := h.db.Query(`
+		SELECT p.id, p.user_id, p.title, p.slug, p.created_at, u.username,
+		       highlight(posts_fts, 0, ?, ?),
+		       snippet(posts_fts, 1, ?, ?, '…', 24),
+		       bm25(posts_fts, 10.0, 1.0, 5.0, 2.0) AS rank
+		FROM posts_fts
+		JOIN posts p ON p.id = posts_fts.rowid
+		JOIN users u ON u.id = p.user_id
+		WHERE posts_fts MATCH ? AND `+publishedPostFilter+`
+		ORDER BY rank
+		LIMIT ? OFFSET ?
+	`, matchStart, matchEnd, matchStart, matchEnd, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		result := &models.SearchResult{}
+		err := rows.Scan(
+			&result.PostID,
+			&result.UserID,
+			&result.Title,
+			&result.Slug,
+			&result.CreatedAt,
+			&result.Author,
+			&result.Highlight,
+			&result.Snippet,
+			&result.Rank,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		result.Highlight = markMatches(result.Highlight)
+		result.Snippet = markMatches(result.Snippet)
+		results = append(results, result)
+	}
+
+	return results, total, rows.Err()
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMatchQuery(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"golang", `"golang"*`},
+		{"  go   generics ", `"go"* "generics"*`},
+		{`title:"foo" OR bar*`, `"title"* "foo"* "OR"* "bar"*`},
+		{"café_au lait", `"café_au"* "lait"*`},
+		{"!!!", ""},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, buildMatchQuery(tc.input), tc.input)
+	}
+}
+
+func TestMarkMatches(t *testing.T) {
+	marked := markMatches("<b>" + matchStart + "go" + matchEnd + "</b>")
+	assert.Equal(t, "&lt;b&gt;<mark>go</mark>&lt;/b&gt;", marked)
+}
+
+func TestSearch(t *testing.T) {
+	db := newTestDatabase(t)
+	if !db.SearchEnabled {
+		t.Skip("SQLite built without FTS5; run with -tags sqlite_fts5")
+	}
+
+	userID := insertTestUser(t, db, "gopher")
+	insertTestPost(t, db, userID, "generics", "Understanding generics", "Type parameters arrived in Go 1.18.")
+	insertTestPost(t, db, userID, "channels", "Channels in depth", "Generics are not covered here.")
+	draftID := insertTestPost(t, db, userID, "draft", "Generics draft", "Unpublished notes.")
+	_, err := db.Exec("UPDATE posts SET status = 'draft' WHERE id = ?", draftID)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/search", NewSearchHandler(db.DB, true).Search)
+
+	w := doJSON(router, "GET", "/search?q=generic", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page struct {
+		Items      []models.SearchResult `json:"items"`
+		TotalItems int64                 `json:"total_items"`
+	}
+	decodeData(t, w, &page)
+
+	require.Equal(t, int64(2), page.TotalItems)
+	assert.Equal(t, "generics", page.Items[0].Slug, "title matches rank first")
+	assert.Contains(t, page.Items[0].Highlight, "<mark>generics</mark>")
+	assert.Contains(t, page.Items[1].Snippet, "<mark>Generics</mark>")
+
+	// Renaming the author keeps the index in sync through the triggers.
+	_, err = db.Exec("UPDATE users SET username = 'rustacean' WHERE id = ?", userID)
+	require.NoError(t, err)
+
+	w = doJSON(router, "GET", "/search?q=rustacean", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &page)
+	assert.Equal(t, int64(2), page.TotalItems)
+
+	w = doJSON(router, "GET", "/search?q=", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/search", NewSearchHandler(nil, false).Search)
+
+	w := doJSON(router, "GET", "/search?q=go", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
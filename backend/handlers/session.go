@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// SessionCache is whatever remembers accounts and sessions between
+// requests; it is told to forget a user whenever they change here.
+type SessionCache interface {
+	Forget(userID int64)
+}
+
+func (h *AuthHandler) forget(userID int64) {
+	if h.sessions != nil {
+		h.sessions.Forget(userID)
+	}
+}
+
+func createSession(exec execer, userID int64, familyID, userAgent, ip string) (int64, error) {
+	now := time.Now().UTC()
+	result, err := exec.Exec(`
+		INSERT INTO sessions (user_id, family_id, user_agent, ip_address, created_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, familyID, userAgent, ip, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetSessions lists the devices the caller is signed in on, most recently
+// seen first, marking the one making the request.
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT s.id, s.user_agent, s.ip_address, s.created_at, s.last_seen_at
+		FROM sessions s
+		WHERE s.user_id = ? AND s.revoked_at IS NULL
+		  AND EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.family_id = s.family_id
+			  AND rt.used_at IS NULL AND rt.revoked_at IS NULL AND rt.expires_at > ?
+		  )
+		ORDER BY s.last_seen_at DESC, s.id DESC
+	`, c.GetInt64("user_id"), time.Now().UTC())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	current := c.GetInt64("session_id")
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		session.Current = session.ID == current
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SuccessResponse(c, sessions)
+}
+
+// RevokeSession signs the caller out on one device. Its refresh token stops
+// working at once and so, through AuthMiddleware, does its access token.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	var familyID string
+	err = h.db.QueryRow(
+		"SELECT family_id FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		sessionID, userID,
+	).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "Session not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := h.revokeSession(userID, familyID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Session revoked"})
+}
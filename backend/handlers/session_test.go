@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	keys := signing.NewHMAC("test-secret")
+	db := newTestDatabase(t)
+	aliceID := insertTestUser(t, db, "alice")
+	bobID := insertTestUser(t, db, "bob")
+
+	gin.SetMode(gin.TestMode)
+	authenticator := middleware.NewAuthenticator(keys, db.DB, time.Minute)
+	auth := NewAuthHandler(db.DB, AuthOptions{Keys: keys, Sessions: authenticator})
+	router := gin.New()
+	router.POST("/refresh", auth.RefreshToken)
+	protected := router.Group("", middleware.AuthMiddleware(authenticator))
+	protected.GET("/me/sessions", auth.GetSessions)
+	protected.DELETE("/me/sessions/:id", auth.RevokeSession)
+
+	signIn := func(userID int64, userAgent string) (string, string) {
+		t.Helper()
+		access, refresh, err := auth.generateTokenPair(userID, models.RoleAuthor, userAgent, "10.0.0.1")
+		require.NoError(t, err)
+		return access, refresh
+	}
+	listSessions := func(token string) []models.Session {
+		t.Helper()
+		w := doJSONWithToken(router, token, "GET", "/me/sessions", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var sessions []models.Session
+		decodeData(t, w, &sessions)
+		return sessions
+	}
+
+	laptop, _ := signIn(aliceID, "Laptop")
+	phone, phoneRefresh := signIn(aliceID, "Phone")
+	bob, _ := signIn(bobID, "Bob's phone")
+
+	sessions := listSessions(laptop)
+	require.Len(t, sessions, 2)
+	byAgent := map[string]models.Session{}
+	for _, session := range sessions {
+		assert.Equal(t, "10.0.0.1", session.IPAddress)
+		byAgent[session.UserAgent] = session
+	}
+	assert.True(t, byAgent["Laptop"].Current)
+	assert.False(t, byAgent["Phone"].Current)
+
+	// Other people's sessions cannot be revoked.
+	bobSessions := listSessions(bob)
+	require.Len(t, bobSessions, 1)
+	url := fmt.Sprintf("/me/sessions/%d", bobSessions[0].ID)
+	assert.Equal(t, http.StatusNotFound, doJSONWithToken(router, laptop, "DELETE", url, nil).Code)
+
+	// Revoking the phone signs it out at once, even though its last
+	// request was answered from the cache.
+	assert.Equal(t, http.StatusOK, doJSONWithToken(router, phone, "GET", "/me/sessions", nil).Code)
+	url = fmt.Sprintf("/me/sessions/%d", byAgent["Phone"].ID)
+	require.Equal(t, http.StatusOK, doJSONWithToken(router, laptop, "DELETE", url, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, doJSONWithToken(router, phone, "GET", "/me/sessions", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, "POST", "/refresh", gin.H{"refreshToken": phoneRefresh}).Code)
+	assert.Equal(t, http.StatusNotFound, doJSONWithToken(router, laptop, "DELETE", url, nil).Code)
+
+	sessions = listSessions(laptop)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "Laptop", sessions[0].UserAgent)
+	assert.Len(t, listSessions(bob), 1)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+type TagHandler struct {
+	db *sql.DB
+}
+
+func NewTagHandler(db *sql.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+func (h *TagHandler) GetTags(c *gin.Context) {
+	prefix := models.NormalizeTag(c.Query("q"))
+
+	tags, err := h.getTags(prefix)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	utils.SuccessResponse(c, tags)
+}
+
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	var input models.TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return
+	}
+
+	name := models.NormalizeTag(input.Name)
+	if name == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag name")
+		return
+	}
+
+	tag, err := h.getOrCreateTag(name)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create tag")
+		return
+	}
+
+	utils.SuccessResponse(c, tag)
+}
+
+// DeleteTag removes a tag that no post uses any more.
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	tagID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid tag ID")
+		return
+	}
+
+	var inUse bool
+	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM post_tags WHERE tag_id = ?)", tagID).Scan(&inUse)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if inUse {
+		utils.ErrorResponse(c, http.StatusConflict, "Tag is still in use")
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM tags WHERE id = ?", tagID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete tag")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Tag deleted successfully"})
+}
+
+func (h *TagHandler) getTags(prefix string) ([]*models.Tag, error) {
+	rows, err := h.db.Query(`
+		SELECT t.id, t.name, t.created_at, COUNT(p.id) AS post_count
+		FROM tags t
+		LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		LEFT JOIN posts p ON p.id = pt.post_id AND `+publishedPostFilter+`
+		WHERE t.name LIKE ? ESCAPE '\'
+		GROUP BY t.id
+		ORDER BY post_count DESC, t.name ASC
+	`, escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (h *TagHandler) getOrCreateTag(name string) (*models.Tag, error) {
+	if _, err := h.db.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", name); err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{}
+	err := h.db.QueryRow(`
+		SELECT t.id, t.name, t.created_at,
+		       (SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id
+		        WHERE pt.tag_id = t.id AND `+publishedPostFilter+`)
+		FROM tags t
+		WHERE t.name = ?
+	`, name).Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.PostCount)
+	return tag, err
+}
+
+// setPostTags replaces the tags on a post, creating any tags that do not
+// exist yet. Names must already be normalized.
+func setPostTags(tx *sql.Tx, postID int64, names []string) error {
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE post_id = ?", postID); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO post_tags (post_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, postID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPostTags fills in Tags on each post with a single query.
+func loadPostTags(db *sql.DB, posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Post, len(posts))
+	args := make([]interface{}, 0, len(posts))
+	for _, post := range posts {
+		post.Tags = []string{}
+		byID[post.ID] = post
+		args = append(args, post.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT pt.post_id, t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id IN (`+placeholders(len(args))+`)
+		ORDER BY t.name
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int64
+		var name string
+		if err := rows.Scan(&postID, &name); err != nil {
+			return err
+		}
+		if post, ok := byID[postID]; ok {
+			post.Tags = append(post.Tags, name)
+		}
+	}
+	return rows.Err()
+}
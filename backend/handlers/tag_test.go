@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags := models.NormalizeTags([]string{"Go", " go ", "GO", "Web  Dev", "c++", "node_js", "", "--"})
+	assert.Equal(t, []string{"go", "web-dev", "c++", "node-js"}, tags)
+}
+
+func TestPostTags(t *testing.T) {
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "tagger")
+	postID := insertTestPost(t, db, userID, "tagged", "A tagged post", "Some content here.")
+	insertTestPost(t, db, userID, "untagged", "Untagged post", "Some other content.")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	postHandler := NewPostHandler(db.DB)
+	tagHandler := NewTagHandler(db.DB)
+	router.GET("/posts", postHandler.GetPosts)
+	router.PUT("/posts/:id", withUser(userID), postHandler.UpdatePost)
+	router.GET("/tags", tagHandler.GetTags)
+	router.DELETE("/tags/:id", tagHandler.DeleteTag)
+
+	w := doJSON(router, "PUT", "/posts/1", models.PostInput{
+		Title:   "A tagged post",
+		Content: "Some content here.",
+		Tags:    []string{"Go", "go", "Databases"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var post models.Post
+	decodeData(t, w, &post)
+	assert.Equal(t, []string{"databases", "go"}, post.Tags)
+
+	w = doJSON(router, "PUT", "/posts/1", models.PostInput{
+		Title:   "A tagged post",
+		Content: "Some content here.",
+		Tags:    []string{strings.Repeat("t", models.MaxTagLength+1)},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	// Omitting tags on update keeps the existing ones.
+	w = doJSON(router, "PUT", "/posts/1", models.PostInput{
+		Title:   "A renamed post",
+		Content: "Some content here.",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	decodeData(t, w, &post)
+	assert.Equal(t, []string{"databases", "go"}, post.Tags)
+
+	w = doJSON(router, "GET", "/posts?tag=GO", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		Items      []models.Post `json:"items"`
+		TotalItems int64         `json:"total_items"`
+	}
+	decodeData(t, w, &page)
+	require.Equal(t, int64(1), page.TotalItems)
+	assert.Equal(t, postID, page.Items[0].ID)
+
+	w = doJSON(router, "GET", "/tags?q=g", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var tags []models.Tag
+	decodeData(t, w, &tags)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "go", tags[0].Name)
+	assert.Equal(t, int64(1), tags[0].PostCount)
+
+	w = doJSON(router, "DELETE", "/tags/1", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/totp"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+const (
+	totpIssuer = "Blogy"
+
+	// challengeTTL is how long the second step of a login may take.
+	challengeTTL = 5 * time.Minute
+
+	recoveryCodeCount = 10
+)
+
+type twoFactorState struct {
+	email   string
+	secret  sql.NullString
+	enabled bool
+}
+
+// EnrollTwoFactor hands out a new secret for ConfirmTwoFactor to enable.
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	state, err := h.getTwoFactorState(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if state.enabled {
+		utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+
+	if _, err := h.db.Exec(`
+		UPDATE users SET totp_secret = ?, totp_last_step = NULL
+		WHERE id = ? AND totp_enabled_at IS NULL
+	`, secret, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start enrolment")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"secret":     secret,
+		"otpauthUri": totp.URI(totpIssuer, state.email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication and returns the
+// recovery codes, shown this once.
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+		return
+	}
+
+	var input models.TwoFactorCodeInput
+	if !bindTwoFactorInput(c, &input) {
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	state, err := h.getTwoFactorState(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if state.enabled {
+		utils.ErrorResponse(c, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !state.secret.Valid {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor enrolment has not been started")
+		return
+	}
+
+	ok, err := h.checkTOTP(userID, state.secret.String, input.Code)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !ok {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid two-factor code")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET totp_enabled_at = ? WHERE id = ?", time.Now().UTC(), userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off given a current or
+// recovery code.
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	if c.GetInt64("impersonator_id") != 0 {
+		utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+		return
+	}
+
+	var input models.TwoFactorCodeInput
+	if !bindTwoFactorInput(c, &input) {
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	state, err := h.getTwoFactorState(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !state.enabled {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	// Wrong codes are throttled like failed logins, or a stolen access
+	// token would let anyone work through the code space here instead.
+	attempt, ok := h.startLoginAttempt(c, accountThrottleKey(userID), ipThrottleKey(c.ClientIP()))
+	if !ok {
+		return
+	}
+
+	ok, err = h.checkSecondFactor(userID, state, input.Code)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !ok {
+		attempt.fail()
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid two-factor code")
+		return
+	}
+
+	if err := attempt.succeed(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL
+		WHERE id = ?
+	`, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// LoginTwoFactor trades Login's challenge token and a code for the token pair.
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var input models.TwoFactorLoginInput
+	if !bindTwoFactorInput(c, &input) {
+		return
+	}
+
+	userID, issuedAt, ok := h.parseChallengeToken(input.ChallengeToken)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	// The account may have been banned, or logged out everywhere, since
+	// the password step.
+	if _, err := middleware.CheckAccount(h.db, userID, issuedAt); err != nil {
+		middleware.AccountErrorResponse(c, err)
+		return
+	}
+
+	// Wrong codes count as failed logins, which keeps anyone who has the
+	// password from working through the code space.
+	attempt, ok := h.startLoginAttempt(c, accountThrottleKey(userID), ipThrottleKey(c.ClientIP()))
+	if !ok {
+		return
+	}
+
+	state, err := h.getTwoFactorState(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	ok = false
+	if state.enabled {
+		ok, err = h.checkSecondFactor(userID, state, input.Code)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+	if !ok {
+		attempt.fail()
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	if err := attempt.succeed(); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	user, err := h.getUserByID(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.respondWithSession(c, user)
+}
+
+func bindTwoFactorInput(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid input format")
+		return false
+	}
+
+	if err := utils.Validate.Struct(input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, utils.FormatValidationErrors(err))
+		return false
+	}
+	return true
+}
+
+func (h *AuthHandler) getTwoFactorState(userID int64) (*twoFactorState, error) {
+	state := &twoFactorState{}
+	err := h.db.QueryRow(`
+		SELECT email, totp_secret, totp_enabled_at IS NOT NULL
+		FROM users WHERE id = ?
+	`, userID).Scan(&state.email, &state.secret, &state.enabled)
+	return state, err
+}
+
+// generateChallengeToken signs a token that only LoginTwoFactor accepts.
+func (h *AuthHandler) generateChallengeToken(userID int64) (string, error) {
+	now := time.Now()
+	return h.keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"type":    "2fa_challenge",
+		"iat":     now.Unix(),
+		"exp":     now.Add(challengeTTL).Unix(),
+	})
+}
+
+func (h *AuthHandler) parseChallengeToken(tokenString string) (int64, time.Time, bool) {
+	token, err := h.keys.Parse(tokenString)
+	if err != nil || !token.Valid {
+		return 0, time.Time{}, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	if tokenType, _ := claims["type"].(string); tokenType != "2fa_challenge" {
+		return 0, time.Time{}, false
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	issuedAt, _ := claims["iat"].(float64)
+	return int64(userID), time.Unix(int64(issuedAt), 0), true
+}
+
+// checkSecondFactor accepts a code from the authenticator app or an unused
+// recovery code, which is then spent.
+func (h *AuthHandler) checkSecondFactor(userID int64, state *twoFactorState, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return h.checkTOTP(userID, state.secret.String, code)
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now().UTC(), userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// checkTOTP accepts a code for secret newer than the last one accepted.
+func (h *AuthHandler) checkTOTP(userID int64, secret, code string) (bool, error) {
+	step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE users SET totp_last_step = ?
+		WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and stores a
+// new set, returning them formatted for display.
+func replaceRecoveryCodes(tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:]
+
+		if _, err := tx.Exec(`
+			INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)
+		`, userID, hashRecoveryCode(code), now); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code however it was typed: case,
+// dashes and spaces do not matter.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
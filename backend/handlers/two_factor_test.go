@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorAuthentication(t *testing.T) {
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "alice")
+
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthHandler(db.DB, AuthOptions{Keys: signing.NewHMAC("test-secret")})
+	router := gin.New()
+	router.POST("/login/2fa", auth.LoginTwoFactor)
+	me := router.Group("/me", withUser(userID))
+	me.POST("/2fa/enroll", auth.EnrollTwoFactor)
+	me.POST("/2fa/confirm", auth.ConfirmTwoFactor)
+	me.DELETE("/2fa", auth.DisableTwoFactor)
+
+	codeAt := func(secret string, steps int64) string {
+		t.Helper()
+		code, err := totp.Code(secret, totp.Step(time.Now())+steps)
+		require.NoError(t, err)
+		return code
+	}
+	login := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		challenge, err := auth.generateChallengeToken(userID)
+		require.NoError(t, err)
+		return doJSON(router, "POST", "/login/2fa", gin.H{"challengeToken": challenge, "code": code})
+	}
+	secondStep := func(code string) int {
+		t.Helper()
+		return login(code).Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "POST", "/me/2fa/confirm", gin.H{"code": "123456"}).Code)
+
+	w := doJSON(router, "POST", "/me/2fa/enroll", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var enrolment struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauthUri"`
+	}
+	decodeData(t, w, &enrolment)
+	assert.True(t, strings.HasPrefix(enrolment.OtpauthURI, "otpauth://totp/Blogy:alice@example.com?"))
+
+	// Two-factor stays off until a code from the app confirms it.
+	assert.Equal(t, http.StatusUnauthorized, secondStep(codeAt(enrolment.Secret, 0)))
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "POST", "/me/2fa/confirm", gin.H{"code": "000000x"}).Code)
+
+	w = doJSON(router, "POST", "/me/2fa/confirm", gin.H{"code": codeAt(enrolment.Secret, 0)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var confirmation struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	decodeData(t, w, &confirmation)
+	require.Len(t, confirmation.RecoveryCodes, recoveryCodeCount)
+	assert.Equal(t, http.StatusConflict, doJSON(router, "POST", "/me/2fa/enroll", nil).Code)
+
+	// The code that confirmed enrolment cannot be used again, the next
+	// one can, once.
+	assert.Equal(t, http.StatusUnauthorized, secondStep(codeAt(enrolment.Secret, 0)))
+	next := codeAt(enrolment.Secret, 1)
+	w = login(next)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var session struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	decodeData(t, w, &session)
+	assert.NotEmpty(t, session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, secondStep(next))
+
+	// Recovery codes work once each, however they are typed.
+	recovery := strings.ToUpper(strings.ReplaceAll(confirmation.RecoveryCodes[0], "-", ""))
+	assert.Equal(t, http.StatusOK, secondStep(recovery))
+	assert.Equal(t, http.StatusUnauthorized, secondStep(recovery))
+
+	// An access token does not pass for a challenge.
+	assert.Equal(t, http.StatusUnauthorized, doJSON(router, "POST", "/login/2fa", gin.H{
+		"challengeToken": session.AccessToken, "code": confirmation.RecoveryCodes[1],
+	}).Code)
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "DELETE", "/me/2fa", gin.H{"code": confirmation.RecoveryCodes[0]}).Code)
+	require.Equal(t, http.StatusOK, doJSON(router, "DELETE", "/me/2fa", gin.H{"code": confirmation.RecoveryCodes[1]}).Code)
+	assert.Equal(t, http.StatusUnauthorized, secondStep(confirmation.RecoveryCodes[2]))
+
+	var remaining int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?", userID).Scan(&remaining))
+	assert.Zero(t, remaining)
+}
+
+func TestDisableTwoFactorThrottled(t *testing.T) {
+	db := newTestDatabase(t)
+	userID := insertTestUser(t, db, "alice")
+
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthHandler(db.DB, AuthOptions{
+		Keys:        signing.NewHMAC("test-secret"),
+		LoginLimits: LoginLimits{MaxFailures: 2, LockoutDuration: time.Hour},
+	})
+	router := gin.New()
+	me := router.Group("/me", withUser(userID))
+	me.POST("/2fa/enroll", auth.EnrollTwoFactor)
+	me.POST("/2fa/confirm", auth.ConfirmTwoFactor)
+	me.DELETE("/2fa", auth.DisableTwoFactor)
+
+	w := doJSON(router, "POST", "/me/2fa/enroll", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var enrolment struct {
+		Secret string `json:"secret"`
+	}
+	decodeData(t, w, &enrolment)
+	code, err := totp.Code(enrolment.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	w = doJSON(router, "POST", "/me/2fa/confirm", gin.H{"code": code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var confirmation struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	decodeData(t, w, &confirmation)
+
+	// Guessing codes with a stolen access token locks the account like
+	// failed logins do, even for a right code afterwards.
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "DELETE", "/me/2fa", gin.H{"code": "000000"}).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(router, "DELETE", "/me/2fa", gin.H{"code": "000001"}).Code)
+	w = doJSON(router, "DELETE", "/me/2fa", gin.H{"code": confirmation.RecoveryCodes[0]})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	var enabled bool
+	require.NoError(t, db.QueryRow("SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = ?", userID).Scan(&enabled))
+	assert.True(t, enabled)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+type UserHandler struct {
+	db *sql.DB
+}
+
+func NewUserHandler(db *sql.DB) *UserHandler {
+	return &UserHandler{db: db}
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	profile, err := h.getProfile(id, c.GetInt64("user_id"))
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	} else if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+
+	utils.SuccessResponse(c, profile)
+}
+
+func (h *UserHandler) Follow(c *gin.Context) {
+	h.setFollow(c, true)
+}
+
+func (h *UserHandler) Unfollow(c *gin.Context) {
+	h.setFollow(c, false)
+}
+
+func (h *UserHandler) GetFollowers(c *gin.Context) {
+	h.listFollows(c, `
+		FROM follows f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.followee_id = ?
+	`)
+}
+
+func (h *UserHandler) GetFollowing(c *gin.Context) {
+	h.listFollows(c, `
+		FROM follows f
+		JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = ?
+	`)
+}
+
+// setFollow is idempotent in both directions, like the like endpoints.
+func (h *UserHandler) setFollow(c *gin.Context, follow bool) {
+	followeeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	if followeeID == userID {
+		utils.ErrorResponse(c, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", followeeID).Scan(&exists); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !exists {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if follow {
+		_, err = h.db.Exec("INSERT OR IGNORE INTO follows (follower_id, followee_id) VALUES (?, ?)", userID, followeeID)
+	} else {
+		_, err = h.db.Exec("DELETE FROM follows WHERE follower_id = ? AND followee_id = ?", userID, followeeID)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update follow")
+		return
+	}
+
+	var count int64
+	if err := h.db.QueryRow("SELECT COUNT(*) FROM follows WHERE followee_id = ?", followeeID).Scan(&count); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"following": follow, "follower_count": count})
+}
+
+// listFollows pages through one side of the follow graph. from selects the
+// users to return, aliased as u, for the user ID in the :id parameter.
+func (h *UserHandler) listFollows(c *gin.Context, from string) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, pageSize, offset := utils.GetPagination(c)
+
+	var total int64
+	if err := h.db.QueryRow("SELECT COUNT(*) "+from, id).Scan(&total); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT u.id, u.username, COALESCE(u.bio, ''), COALESCE(u.avatar_url, ''), u.created_at
+	`+from+`
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT ? OFFSET ?
+	`, id, pageSize, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	defer rows.Close()
+
+	users := []*models.UserProfile{}
+	for rows.Next() {
+		user := &models.UserProfile{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.Bio, &user.AvatarURL, &user.CreatedAt); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch users")
+			return
+		}
+		users = append(users, user)
+	}
+
+	utils.PaginatedSuccessResponse(c, users, total, page, pageSize)
+}
+
+func (h *UserHandler) getProfile(id, viewerID int64) (*models.UserProfile, error) {
+	profile := &models.UserProfile{}
+	err := h.db.QueryRow(`
+		SELECT u.id, u.username, COALESCE(u.bio, ''), COALESCE(u.avatar_url, ''), u.created_at,
+		       (SELECT COUNT(*) FROM follows WHERE followee_id = u.id),
+		       (SELECT COUNT(*) FROM follows WHERE follower_id = u.id),
+		       (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND `+publishedPostFilter+`),
+		       EXISTS(SELECT 1 FROM follows WHERE follower_id = ? AND followee_id = u.id)
+		FROM users u
+		WHERE u.id = ?
+	`, viewerID, id).Scan(
+		&profile.ID,
+		&profile.Username,
+		&profile.Bio,
+		&profile.AvatarURL,
+		&profile.CreatedAt,
+		&profile.FollowerCount,
+		&profile.FollowingCount,
+		&profile.PostCount,
+		&profile.FollowedByMe,
+	)
+	return profile, err
+}
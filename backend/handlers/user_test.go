@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFollowAndFeed(t *testing.T) {
+	db := newTestDatabase(t)
+	readerID := insertTestUser(t, db, "reader")
+	followedID := insertTestUser(t, db, "followed")
+	strangerID := insertTestUser(t, db, "stranger")
+
+	for i := 1; i <= 3; i++ {
+		insertTestPost(t, db, followedID, fmt.Sprintf("followed-%d", i), fmt.Sprintf("Followed post %d", i), "Some content here.")
+	}
+	insertTestPost(t, db, strangerID, "stranger", "Stranger post", "Some content here.")
+
+	gin.SetMode(gin.TestMode)
+	users := NewUserHandler(db.DB)
+	posts := NewPostHandler(db.DB)
+
+	router := gin.New()
+	reader := router.Group("", withUser(readerID))
+	reader.POST("/users/:id/follow", users.Follow)
+	reader.DELETE("/users/:id/follow", users.Unfollow)
+	reader.GET("/users/:id", users.GetUser)
+	reader.GET("/feed", posts.GetFeed)
+	router.GET("/users/:id/followers", users.GetFollowers)
+
+	w := doJSON(router, "POST", fmt.Sprintf("/users/%d/follow", readerID), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(router, "POST", fmt.Sprintf("/users/%d/follow", followedID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var profile models.UserProfile
+	w = doJSON(router, "GET", fmt.Sprintf("/users/%d", followedID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &profile)
+	assert.Equal(t, int64(1), profile.FollowerCount)
+	assert.Equal(t, int64(3), profile.PostCount)
+	assert.True(t, profile.FollowedByMe)
+
+	var followers struct {
+		Items      []models.UserProfile `json:"items"`
+		TotalItems int64                `json:"total_items"`
+	}
+	w = doJSON(router, "GET", fmt.Sprintf("/users/%d/followers", followedID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &followers)
+	require.Equal(t, int64(1), followers.TotalItems)
+	assert.Equal(t, "reader", followers.Items[0].Username)
+
+	var feed struct {
+		Items      []models.Post `json:"items"`
+		NextCursor string        `json:"next_cursor"`
+	}
+	w = doJSON(router, "GET", "/feed?limit=2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &feed)
+	require.Len(t, feed.Items, 2)
+	assert.Equal(t, "Followed post 3", feed.Items[0].Title)
+	assert.Equal(t, "Followed post 2", feed.Items[1].Title)
+	require.NotEmpty(t, feed.NextCursor)
+
+	w = doJSON(router, "GET", "/feed?limit=2&cursor="+feed.NextCursor, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	feed.NextCursor = ""
+	decodeData(t, w, &feed)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "Followed post 1", feed.Items[0].Title)
+	assert.Empty(t, feed.NextCursor)
+
+	w = doJSON(router, "GET", "/feed?cursor=!!", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doJSON(router, "DELETE", fmt.Sprintf("/users/%d/follow", followedID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = doJSON(router, "GET", "/feed", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &feed)
+	assert.Empty(t, feed.Items)
+}
+
+func TestFeedOrdersByPublicationTime(t *testing.T) {
+	db := newTestDatabase(t)
+	readerID := insertTestUser(t, db, "reader")
+	authorID := insertTestUser(t, db, "author")
+	_, err := db.Exec("INSERT INTO follows (follower_id, followee_id) VALUES (?, ?)", readerID, authorID)
+	require.NoError(t, err)
+
+	// The scheduled post is drafted first, so it has the lowest ID, but
+	// goes out after the others.
+	now := time.Now().UTC()
+	scheduled := insertTestPost(t, db, authorID, "scheduled", "Scheduled post", "Some content here.")
+	_, err = db.Exec("UPDATE posts SET status = 'draft', published_at = NULL, publish_at = ? WHERE id = ?", now.Add(-time.Minute), scheduled)
+	require.NoError(t, err)
+	for i, age := range []time.Duration{2 * time.Hour, time.Hour} {
+		id := insertTestPost(t, db, authorID, fmt.Sprintf("earlier-%d", i), fmt.Sprintf("Earlier post %d", i), "Some content here.")
+		_, err := db.Exec("UPDATE posts SET published_at = ? WHERE id = ?", now.Add(-age), id)
+		require.NoError(t, err)
+	}
+
+	published, err := scheduler.NewPublisher(db.DB, time.Minute, zap.NewNop()).PublishDue(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), published)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/feed", withUser(readerID), NewPostHandler(db.DB).GetFeed)
+	router.GET("/posts", NewPostHandler(db.DB).GetPosts)
+
+	var titles []string
+	cursor := ""
+	for {
+		var feed struct {
+			Items      []models.Post `json:"items"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		w := doJSON(router, "GET", "/feed?limit=1&cursor="+cursor, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		decodeData(t, w, &feed)
+		for _, post := range feed.Items {
+			titles = append(titles, post.Title)
+		}
+		if cursor = feed.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"Scheduled post", "Earlier post 1", "Earlier post 0"}, titles)
+
+	var list struct {
+		Items []models.Post `json:"items"`
+	}
+	w := doJSON(router, "GET", "/posts", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	decodeData(t, w, &list)
+	require.Len(t, list.Items, 3)
+	assert.Equal(t, "Scheduled post", list.Items[0].Title)
+	require.NotNil(t, list.Items[0].PublishedAt)
+}
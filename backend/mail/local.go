@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer writes each message to its own .eml file in a directory,
+// where any mail client can open it.
+type FileMailer struct {
+	dir  string
+	from string
+
+	mu   sync.Mutex
+	sent int
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+
+	m.mu.Lock()
+	m.sent++
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102-150405"), m.sent)
+	m.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg, now), 0o600)
+}
+
+// ConsoleMailer prints messages, for development without any mail setup.
+type ConsoleMailer struct {
+	w    io.Writer
+	from string
+	mu   sync.Mutex
+}
+
+func NewConsoleMailer(w io.Writer, from string) *ConsoleMailer {
+	return &ConsoleMailer{w: w, from: from}
+}
+
+func (m *ConsoleMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.w, "----- mail -----\n%s\n----------------\n", format(m.from, msg, time.Now()))
+	return err
+}
@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFormatKeepsHeadersOnOneLine(t *testing.T) {
+	msg := format("Blogy <no-reply@blogy.test>", Message{
+		To:      "alice@example.com\r\nBcc: mallory@example.com",
+		Subject: "Hello\nBcc: mallory@example.com",
+		Body:    "line one\nline two",
+	}, time.Unix(0, 0))
+
+	headers, body, found := strings.Cut(string(msg), "\r\n\r\n")
+	require.True(t, found)
+	for _, line := range strings.Split(headers, "\r\n") {
+		assert.False(t, strings.HasPrefix(line, "Bcc:"), line)
+	}
+	assert.Contains(t, headers, "From: Blogy <no-reply@blogy.test>")
+	assert.Equal(t, "line one\r\nline two", body)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFileMailer(dir, "no-reply@blogy.test")
+	require.NoError(t, err)
+
+	require.NoError(t, mailer.Send(Message{To: "alice@example.com", Subject: "One", Body: "first"}))
+	require.NoError(t, mailer.Send(Message{To: "bob@example.com", Subject: "Two", Body: "second"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: alice@example.com")
+}
+
+func TestQueue(t *testing.T) {
+	var out bytes.Buffer
+	queue := NewQueue(NewConsoleMailer(&out, "no-reply@blogy.test"), 1, zap.NewNop())
+
+	require.NoError(t, queue.Send(Message{To: "alice@example.com", Subject: "Queued", Body: "hello"}))
+	assert.ErrorIs(t, queue.Send(Message{To: "bob@example.com"}), ErrQueueFull)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		queue.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return len(queue.messages) == 0 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Contains(t, out.String(), "Subject: Queued")
+}
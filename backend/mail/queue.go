@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Queue hands messages to a Mailer in the background, so that requests do
+// not wait on the mail server and their timing does not give away whether
+// a message was sent.
+type Queue struct {
+	mailer   Mailer
+	logger   *zap.Logger
+	messages chan Message
+}
+
+func NewQueue(mailer Mailer, size int, logger *zap.Logger) *Queue {
+	return &Queue{
+		mailer:   mailer,
+		logger:   logger,
+		messages: make(chan Message, size),
+	}
+}
+
+// Send queues msg. It fails only when the queue is full.
+func (q *Queue) Send(msg Message) error {
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends queued messages until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			if pending := len(q.messages); pending > 0 {
+				q.logger.Warn("Unsent mail dropped at shutdown", zap.Int("count", pending))
+			}
+			return
+		case msg := <-q.messages:
+			if err := q.mailer.Send(msg); err != nil {
+				q.logger.Error("Failed to send mail", zap.String("subject", msg.Subject), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer delivers mail through an SMTP server, using STARTTLS when the
+// server offers it.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP sends through the server at addr (host:port) as from, which may
+// include a display name. Without a username no authentication is tried.
+func NewSMTP(addr, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	sender, err := netmail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	recipient, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, sender.Address, []string{recipient.Address},
+		format(m.from, msg, time.Now()))
+}
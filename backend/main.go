@@ -6,15 +6,24 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/breach"
 	"github.com/prem0x01/Blogy/config"
 	"github.com/prem0x01/Blogy/database"
 	"github.com/prem0x01/Blogy/database/migrations"
 	"github.com/prem0x01/Blogy/handlers"
+	"github.com/prem0x01/Blogy/mail"
 	"github.com/prem0x01/Blogy/middleware"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/passhash"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/scheduler"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/prem0x01/Blogy/spam"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
@@ -38,6 +47,12 @@ func init() {
 func main() {
 
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+	if !models.ValidCommentMode(cfg.CommentModeration) {
+		logger.Fatal("Invalid comment moderation mode", zap.String("mode", cfg.CommentModeration))
+	}
 
 	dbConfig := &database.Config{
 		MaxOpenConns:    25,
@@ -56,7 +71,59 @@ func main() {
 		logger.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
-	router := setupRouter(cfg, db, logger)
+	if cfg.AdminEmail != "" {
+		if found, err := handlers.PromoteAdmin(db.DB, cfg.AdminEmail); err != nil {
+			logger.Fatal("Failed to promote administrator", zap.Error(err))
+		} else if !found {
+			logger.Warn("No account with a verified address found for ADMIN_EMAIL", zap.String("email", cfg.AdminEmail))
+		}
+	}
+
+	if rendered, err := handlers.RenderPendingPosts(db.DB); err != nil {
+		logger.Fatal("Failed to render post content", zap.Error(err))
+	} else if rendered > 0 {
+		logger.Info("Rendered content for existing posts", zap.Int("count", rendered))
+	}
+
+	if !db.SearchEnabled {
+		logger.Warn("SQLite was built without FTS5, search is disabled (build with -tags sqlite_fts5)")
+	}
+
+	keys := signing.NewHMAC(cfg.JWTSecret)
+	if cfg.JWTKeyDir != "" {
+		if keys, err = signing.Load(cfg.JWTKeyDir, cfg.JWTSigningKey, cfg.JWTSecret); err != nil {
+			logger.Fatal("Failed to load signing keys", zap.Error(err))
+		}
+	} else if cfg.JWTSecret == "" {
+		logger.Warn("JWT_SECRET is empty, tokens are signed with an empty key")
+	}
+
+	var mailer mail.Mailer
+	switch {
+	case cfg.SMTPAddr != "":
+		mailer = mail.NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case cfg.MailDir != "":
+		if mailer, err = mail.NewFileMailer(cfg.MailDir, cfg.MailFrom); err != nil {
+			logger.Fatal("Failed to create mail directory", zap.Error(err))
+		}
+	default:
+		if cfg.Environment == "production" {
+			logger.Warn("SMTP_ADDR is not set, emails are only printed to the console")
+		}
+		mailer = mail.NewConsoleMailer(os.Stdout, cfg.MailFrom)
+	}
+	mailQueue := mail.NewQueue(mailer, 100, logger)
+
+	var breached *breach.Corpus
+	if cfg.BreachedPasswordsPath != "" {
+		if breached, err = breach.Load(cfg.BreachedPasswordsPath, cfg.BreachedPasswordsIndex); err != nil {
+			logger.Fatal("Failed to load breached passwords", zap.Error(err))
+		}
+		defer breached.Close()
+		logger.Info("Loaded breached passwords", zap.Int("count", breached.Len()))
+	}
+
+	router := setupRouter(cfg, db, keys, mailQueue, breached, logger)
 
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
@@ -69,6 +136,22 @@ func main() {
 		}
 	}()
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	publisher := scheduler.NewPublisher(db.DB, cfg.PublishInterval, logger)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		publisher.Run(workerCtx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		mailQueue.Run(workerCtx)
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -80,10 +163,13 @@ func main() {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	stopWorkers()
+	workers.Wait()
+
 	logger.Info("Server exiting")
 }
 
-func setupRouter(cfg *config.Config, db *database.Database, logger *zap.Logger) *gin.Engine {
+func setupRouter(cfg *config.Config, db *database.Database, keys *signing.Keys, mailer mail.Mailer, breached *breach.Corpus, logger *zap.Logger) *gin.Engine {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -102,6 +188,11 @@ func setupRouter(cfg *config.Config, db *database.Database, logger *zap.Logger)
 
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	router.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, keys.JWKS())
+	})
+
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status":     "ok",
@@ -110,29 +201,133 @@ func setupRouter(cfg *config.Config, db *database.Database, logger *zap.Logger)
 		})
 	})
 
-	authHandler := handlers.NewAuthHandler(db.DB, cfg.JWTSecret)
+	authenticator := middleware.NewAuthenticator(keys, db.DB, cfg.SessionCacheTTL)
+	authHandler := handlers.NewAuthHandler(db.DB, handlers.AuthOptions{
+		Keys:     keys,
+		Sessions: authenticator,
+		Mailer:   mailer,
+		AppURL:   cfg.AppURL,
+		LoginLimits: handlers.LoginLimits{
+			MaxFailures:     cfg.LoginMaxFailures,
+			MaxIPFailures:   cfg.LoginMaxIPFailures,
+			LockoutDuration: cfg.LoginLockoutDuration,
+			BaseDelay:       cfg.LoginBaseDelay,
+			MaxDelay:        cfg.LoginMaxDelay,
+		},
+		Hasher:            passwordHasher(cfg),
+		BreachedPasswords: breached,
+	})
 	postHandler := handlers.NewPostHandler(db.DB)
-	commentHandler := handlers.NewCommentHandler(db.DB)
+	spamChecker := spam.NewClassifier(db.DB)
+	commentHandler := handlers.NewCommentHandler(db.DB, handlers.CommentOptions{
+		TreeDepth:        cfg.CommentTreeDepth,
+		RepliesPerBranch: cfg.CommentRepliesPerBranch,
+		EditWindow:       cfg.CommentEditWindow,
+		DefaultMode:      cfg.CommentModeration,
+		Spam:             spamChecker,
+		SpamThreshold:    cfg.SpamThreshold,
+	})
+	moderationHandler := handlers.NewModerationHandler(db.DB, spamChecker)
+	searchHandler := handlers.NewSearchHandler(db.DB, db.SearchEnabled)
+	tagHandler := handlers.NewTagHandler(db.DB)
+	likeHandler := handlers.NewLikeHandler(db.DB)
+	userHandler := handlers.NewUserHandler(db.DB)
+	adminHandler := handlers.NewAdminHandler(db.DB, keys, cfg.ImpersonationTTL, authenticator)
+
+	optionalAuth := middleware.OptionalAuthMiddleware(authenticator)
+	verified := middleware.RequireVerifiedEmail(cfg.RequireVerifiedEmail)
 
 	api := router.Group("/api")
 	{
 		api.POST("/register", authHandler.Register)
 		api.POST("/login", authHandler.Login)
+		api.POST("/login/2fa", authHandler.LoginTwoFactor)
 		api.POST("/refresh", authHandler.RefreshToken)
-		api.GET("/posts", postHandler.GetPosts)
-		api.GET("/posts/:id", postHandler.GetPost)
-		api.GET("/posts/:id/comments", commentHandler.GetComments)
+		api.POST("/logout", authHandler.Logout)
+		api.POST("/password/forgot", authHandler.ForgotPassword)
+		api.POST("/password/reset", authHandler.ResetPassword)
+		api.GET("/verify-email", authHandler.VerifyEmail)
+		api.GET("/posts", optionalAuth, postHandler.GetPosts)
+		api.GET("/posts/:id", optionalAuth, postHandler.GetPost)
+		api.GET("/posts/by-slug/:slug", optionalAuth, postHandler.GetPostBySlug)
+		api.GET("/posts/:id/comments", optionalAuth, commentHandler.GetComments)
+		api.GET("/search", searchHandler.Search)
+		api.GET("/tags", tagHandler.GetTags)
+		api.GET("/users/:id", optionalAuth, userHandler.GetUser)
+		api.GET("/users/:id/followers", userHandler.GetFollowers)
+		api.GET("/users/:id/following", userHandler.GetFollowing)
 
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+		protected.Use(middleware.AuthMiddleware(authenticator))
 		{
-			protected.POST("/posts", postHandler.CreatePost)
+			protected.POST("/logout-all", authHandler.LogoutAll)
+			protected.GET("/me/sessions", authHandler.GetSessions)
+			protected.DELETE("/me/sessions/:id", authHandler.RevokeSession)
+			protected.POST("/me/2fa/enroll", authHandler.EnrollTwoFactor)
+			protected.POST("/me/2fa/confirm", authHandler.ConfirmTwoFactor)
+			protected.DELETE("/me/2fa", authHandler.DisableTwoFactor)
+			protected.POST("/me/email", authHandler.ChangeEmail)
+			protected.POST("/me/email/verification", authHandler.ResendVerification)
+			protected.POST("/posts", middleware.RequirePermission(policy.CreatePosts), verified, postHandler.CreatePost)
 			protected.PUT("/posts/:id", postHandler.UpdatePost)
 			protected.DELETE("/posts/:id", postHandler.DeletePost)
-			protected.POST("/posts/:id/comments", commentHandler.CreateComment)
+			protected.POST("/posts/:id/publish", postHandler.PublishPost)
+			protected.POST("/posts/:id/unpublish", postHandler.UnpublishPost)
+			protected.POST("/posts/:id/archive", postHandler.ArchivePost)
+			protected.POST("/render", postHandler.RenderPreview)
+			protected.GET("/posts/:id/revisions", postHandler.GetRevisions)
+			protected.GET("/posts/:id/revisions/:rev", postHandler.GetRevision)
+			protected.GET("/posts/:id/revisions/:rev/diff", postHandler.DiffRevisions)
+			protected.POST("/posts/:id/revisions/:rev/restore", postHandler.RestoreRevision)
+			protected.GET("/me/posts", postHandler.GetMyPosts)
+			protected.POST("/posts/:id/comments", verified, commentHandler.CreateComment)
+			protected.PUT("/comments/:id", commentHandler.UpdateComment)
 			protected.DELETE("/comments/:id", commentHandler.DeleteComment)
+			protected.GET("/comments/:id/history", commentHandler.GetCommentHistory)
+			protected.PUT("/posts/:id/comment-settings", postHandler.UpdateCommentSettings)
+			protected.GET("/moderation/comments", moderationHandler.GetQueue)
+			protected.POST("/moderation/comments", moderationHandler.BulkModerate)
+			protected.POST("/moderation/comments/:id/approve", moderationHandler.ApproveComment)
+			protected.POST("/moderation/comments/:id/reject", moderationHandler.RejectComment)
+			protected.POST("/posts/:id/like", likeHandler.LikePost)
+			protected.DELETE("/posts/:id/like", likeHandler.UnlikePost)
+			protected.POST("/posts/:id/comments/:commentId/like", likeHandler.LikeComment)
+			protected.DELETE("/posts/:id/comments/:commentId/like", likeHandler.UnlikeComment)
+			protected.POST("/users/:id/follow", userHandler.Follow)
+			protected.DELETE("/users/:id/follow", userHandler.Unfollow)
+			protected.GET("/feed", postHandler.GetFeed)
+			protected.POST("/tags", middleware.RequirePermission(policy.CreatePosts), tagHandler.CreateTag)
+			protected.DELETE("/tags/:id", middleware.RequirePermission(policy.ManageTags), tagHandler.DeleteTag)
+		}
+
+		admin := protected.Group("/admin")
+		admin.Use(middleware.RequirePermission(policy.ManageUsers))
+		{
+			admin.GET("/users", adminHandler.SearchUsers)
+			admin.GET("/users/:id", adminHandler.GetUser)
+			admin.POST("/users/:id/suspend", adminHandler.SuspendUser)
+			admin.DELETE("/users/:id/suspend", adminHandler.UnsuspendUser)
+			admin.POST("/users/:id/ban", adminHandler.BanUser)
+			admin.DELETE("/users/:id/ban", adminHandler.UnbanUser)
+			admin.PUT("/users/:id/role", adminHandler.ChangeRole)
+			admin.POST("/users/:id/logout", adminHandler.ForceLogout)
+			admin.POST("/users/:id/impersonate", middleware.RequirePermission(policy.ImpersonateUsers), adminHandler.Impersonate)
+			admin.GET("/audit-log", adminHandler.GetAuditLog)
 		}
 	}
 
 	return router
 }
+
+// passwordHasher returns the hasher cfg asks for; Validate has checked the
+// name.
+func passwordHasher(cfg *config.Config) passhash.Hasher {
+	if cfg.PasswordHasher == "bcrypt" {
+		return passhash.NewBcrypt(cfg.BcryptCost)
+	}
+	params := passhash.DefaultArgon2idParams
+	params.Memory = uint32(cfg.Argon2Memory)
+	params.Iterations = uint32(cfg.Argon2Iterations)
+	params.Parallelism = uint8(cfg.Argon2Parallelism)
+	return passhash.NewArgon2id(params)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+var (
+	ErrAccountNotFound  = errors.New("account not found")
+	ErrAccountBanned    = errors.New("account is banned")
+	ErrAccountSuspended = errors.New("account is suspended")
+	ErrTokenRevoked     = errors.New("token has been revoked")
+	ErrSessionRevoked   = errors.New("session has been revoked")
+)
+
+// CheckAccount returns the current role of the account a token was issued
+// to, or why its tokens are no longer accepted.
+func CheckAccount(db *sql.DB, userID int64, issuedAt time.Time) (string, error) {
+	account, err := loadAccount(db, userID)
+	if err != nil {
+		return "", err
+	}
+	return account.check(issuedAt)
+}
+
+// accountState is what CheckAccount needs to know about an account.
+type accountState struct {
+	role            string
+	emailVerified   bool
+	banned          bool
+	suspendedUntil  sql.NullTime
+	tokensRevokedAt sql.NullTime
+}
+
+func loadAccount(db *sql.DB, userID int64) (accountState, error) {
+	var account accountState
+	var bannedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT role, email_verified_at IS NOT NULL, banned_at, suspended_until, tokens_revoked_at
+		FROM users
+		WHERE id = ?
+	`, userID).Scan(&account.role, &account.emailVerified, &bannedAt, &account.suspendedUntil, &account.tokensRevokedAt)
+	if err == sql.ErrNoRows {
+		return account, ErrAccountNotFound
+	}
+	account.banned = bannedAt.Valid
+	return account, err
+}
+
+func (a accountState) check(issuedAt time.Time) (string, error) {
+	if err := a.verify(issuedAt); err != nil {
+		return "", err
+	}
+	return a.role, nil
+}
+
+// verify reports why tokens issued at issuedAt are no longer accepted, if
+// they are not.
+func (a accountState) verify(issuedAt time.Time) error {
+	switch {
+	case a.banned:
+		return ErrAccountBanned
+	case a.suspendedUntil.Valid && a.suspendedUntil.Time.After(time.Now()):
+		return ErrAccountSuspended
+	// Revocations are stored in whole seconds, like token times, so a token
+	// issued in the same second came after it.
+	case a.tokensRevokedAt.Valid && issuedAt.Before(a.tokensRevokedAt.Time):
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// AccountErrorResponse writes the response for an error from CheckAccount.
+func AccountErrorResponse(c *gin.Context, err error) {
+	switch err {
+	case ErrAccountBanned:
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is banned")
+	case ErrAccountSuspended:
+		utils.ErrorResponse(c, http.StatusForbidden, "Account is suspended")
+	case ErrTokenRevoked:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Token has been revoked")
+	case ErrSessionRevoked:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Session has been revoked")
+	case ErrAccountNotFound:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Database error")
+	}
+}
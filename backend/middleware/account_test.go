@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountVerify(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	revoked := accountState{tokensRevokedAt: sql.NullTime{Time: now, Valid: true}}
+
+	tests := []struct {
+		name     string
+		account  accountState
+		issuedAt time.Time
+		want     error
+	}{
+		{"active", accountState{}, now, nil},
+		{"banned", accountState{banned: true}, now, ErrAccountBanned},
+		{"suspended", accountState{suspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true}}, now, ErrAccountSuspended},
+		{"suspension over", accountState{suspendedUntil: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}}, now, nil},
+		{"issued before revocation", revoked, now.Add(-time.Second), ErrTokenRevoked},
+		{"issued in the revocation's second", revoked, now, nil},
+		{"issued after revocation", revoked, now.Add(time.Second), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.account.verify(tt.issuedAt))
+		})
+	}
+}
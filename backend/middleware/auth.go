@@ -1,16 +1,23 @@
 package middleware
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prem0x01/Blogy/signing"
 	"github.com/prem0x01/Blogy/utils"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+var errInvalidClaims = errors.New("invalid token claims")
+
+// AuthMiddleware requires a valid access token and sets user_id, role,
+// email_verified, session_id and impersonator_id. The role is read from the
+// database so that changes apply straight away.
+func AuthMiddleware(auth *Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -26,29 +33,98 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		tokenString := parts[1]
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil {
+		claims, err := parseAccessToken(parts[1], auth.keys)
+		if err == errInvalidClaims {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+			c.Abort()
+			return
+		} else if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token")
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-
-			c.Set("user_id", int64(claims["user_id"].(float64)))
-			c.Next()
-		} else {
-			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid token claims")
+		account, err := auth.caller(claims)
+		if err != nil {
+			AccountErrorResponse(c, err)
 			c.Abort()
 			return
 		}
+
+		setCaller(c, claims, account)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware sets the caller like AuthMiddleware when there is a
+// valid token and otherwise lets the request through anonymously.
+func OptionalAuthMiddleware(auth *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := parseAccessToken(parts[1], auth.keys); err == nil {
+				if account, err := auth.caller(claims); err == nil {
+					setCaller(c, claims, account)
+				}
+			}
+		}
+		c.Next()
 	}
 }
+
+func setCaller(c *gin.Context, claims accessClaims, account accountState) {
+	c.Set("user_id", claims.userID)
+	c.Set("role", account.role)
+	c.Set("email_verified", account.emailVerified)
+	if claims.sessionID != 0 {
+		c.Set("session_id", claims.sessionID)
+	}
+	if claims.impersonatorID != 0 {
+		c.Set("impersonator_id", claims.impersonatorID)
+	}
+}
+
+type accessClaims struct {
+	userID         int64
+	issuedAt       time.Time
+	sessionID      int64
+	impersonatorID int64
+}
+
+func parseAccessToken(tokenString string, keys *signing.Keys) (accessClaims, error) {
+	token, err := keys.Parse(tokenString)
+	if err != nil {
+		return accessClaims{}, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return accessClaims{}, errInvalidClaims
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return accessClaims{}, errInvalidClaims
+	}
+
+	// Refresh tokens used to be signed with the same key, so make sure one
+	// is not being passed off as an access token.
+	if tokenType, _ := claims["type"].(string); tokenType != "access" {
+		return accessClaims{}, errInvalidClaims
+	}
+
+	// Tokens issued before iat was added count as issued at the epoch, so
+	// any forced logout covers them.
+	issuedAt, _ := claims["iat"].(float64)
+	// Tokens issued before sessions existed, and impersonation tokens,
+	// carry no session.
+	sessionID, _ := claims["sid"].(float64)
+	impersonatorID, _ := claims["impersonator_id"].(float64)
+
+	return accessClaims{
+		userID:         int64(userID),
+		issuedAt:       time.Unix(int64(issuedAt), 0),
+		sessionID:      int64(sessionID),
+		impersonatorID: int64(impersonatorID),
+	}, nil
+}
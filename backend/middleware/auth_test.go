@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prem0x01/Blogy/database"
+	"github.com/prem0x01/Blogy/database/migrations"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	db, err := database.NewDatabase(":memory:", &database.Config{MaxOpenConns: 1, MaxIdleConns: 1})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, migrations.RunMigrations(db.DB))
+
+	insertUser := func(username, role, extra string) int64 {
+		t.Helper()
+		result, err := db.Exec(`
+			INSERT INTO users (username, email, password_hash, role)
+			VALUES (?, ?, 'x', ?)
+		`, username, username+"@example.com", role)
+		require.NoError(t, err)
+		id, err := result.LastInsertId()
+		require.NoError(t, err)
+		if extra != "" {
+			_, err = db.Exec("UPDATE users SET "+extra+" WHERE id = ?", id)
+			require.NoError(t, err)
+		}
+		return id
+	}
+	author := insertUser("author", models.RoleAuthor, "email_verified_at = CURRENT_TIMESTAMP")
+	admin := insertUser("admin", models.RoleAdmin, "")
+	moderator := insertUser("moderator", models.RoleModerator, "")
+	banned := insertUser("banned", models.RoleAuthor, "banned_at = CURRENT_TIMESTAMP")
+	suspended := insertUser("suspended", models.RoleAuthor, "suspended_until = datetime('now', '+1 day')")
+
+	keys := signing.NewHMAC("test-secret")
+	token := func(claims jwt.MapClaims) string {
+		t.Helper()
+		now := time.Now()
+		full := jwt.MapClaims{"type": "access", "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()}
+		for k, v := range claims {
+			full[k] = v
+		}
+		signed, err := keys.Sign(full)
+		require.NoError(t, err)
+		return signed
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", AuthMiddleware(NewAuthenticator(keys, db.DB, 0)), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"role":            c.GetString("role"),
+			"email_verified":  c.GetBool("email_verified"),
+			"impersonator_id": c.GetInt64("impersonator_id"),
+		})
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+		body   string
+	}{
+		{
+			name:   "role comes from the database",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": author, "role": models.RoleAdmin}),
+			want:   http.StatusOK,
+			body:   `{"email_verified":true,"impersonator_id":0,"role":"author"}`,
+		},
+		{
+			name:   "impersonation by an administrator",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": author, "impersonator_id": admin}),
+			want:   http.StatusOK,
+			body:   `{"email_verified":true,"impersonator_id":2,"role":"author"}`,
+		},
+		{
+			name:   "impersonation by someone who may not",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": author, "impersonator_id": moderator}),
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "banned account",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": banned}),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "suspended account",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": suspended}),
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "unknown account",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": 999}),
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "refresh token",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": author, "type": "refresh"}),
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "no user",
+			header: "Bearer " + token(jwt.MapClaims{}),
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "bad signature",
+			header: "Bearer " + token(jwt.MapClaims{"user_id": author}) + "x",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "not a bearer token",
+			header: "Basic dXNlcjpwYXNz",
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name: "no header",
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.want, w.Code, w.Body.String())
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
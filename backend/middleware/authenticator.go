@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/signing"
+)
+
+// maxCachedEntries bounds the cache; expired entries are swept once it is
+// reached.
+const maxCachedEntries = 10000
+
+// Authenticator verifies access tokens, caching accounts and sessions for
+// cacheTTL. Forget drops a user's entries when something about them changes.
+type Authenticator struct {
+	keys     *signing.Keys
+	db       *sql.DB
+	cacheTTL time.Duration
+
+	mu       sync.Mutex
+	accounts map[int64]cachedAccount
+	sessions map[int64]cachedSession
+}
+
+type cachedAccount struct {
+	state   accountState
+	expires time.Time
+}
+
+type cachedSession struct {
+	userID  int64
+	live    bool
+	expires time.Time
+}
+
+func NewAuthenticator(keys *signing.Keys, db *sql.DB, cacheTTL time.Duration) *Authenticator {
+	return &Authenticator{
+		keys:     keys,
+		db:       db,
+		cacheTTL: cacheTTL,
+		accounts: map[int64]cachedAccount{},
+		sessions: map[int64]cachedSession{},
+	}
+}
+
+// Forget drops everything cached about userID and their sessions.
+func (a *Authenticator) Forget(userID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.accounts, userID)
+	for id, session := range a.sessions {
+		if session.userID == userID {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+// caller checks the account, session and any impersonator behind a token.
+func (a *Authenticator) caller(claims accessClaims) (accountState, error) {
+	account, err := a.checkAccount(claims.userID, claims.issuedAt)
+	if err != nil {
+		return accountState{}, err
+	}
+
+	if claims.sessionID != 0 {
+		live, err := a.sessionLive(claims.sessionID, claims.userID)
+		if err != nil {
+			return accountState{}, err
+		}
+		if !live {
+			return accountState{}, ErrSessionRevoked
+		}
+	}
+
+	if claims.impersonatorID == 0 {
+		return account, nil
+	}
+
+	admin, err := a.checkAccount(claims.impersonatorID, claims.issuedAt)
+	switch err {
+	case nil:
+		if !policy.Can(admin.role, policy.ImpersonateUsers) {
+			return accountState{}, ErrTokenRevoked
+		}
+	case ErrAccountNotFound, ErrAccountBanned, ErrAccountSuspended, ErrTokenRevoked:
+		return accountState{}, ErrTokenRevoked
+	default:
+		return accountState{}, err
+	}
+	return account, nil
+}
+
+// checkAccount returns the account userID if tokens issued to it at
+// issuedAt are still accepted.
+func (a *Authenticator) checkAccount(userID int64, issuedAt time.Time) (accountState, error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.accounts[userID]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.state, cached.state.verify(issuedAt)
+	}
+
+	account, err := loadAccount(a.db, userID)
+	if err != nil {
+		return accountState{}, err
+	}
+
+	a.mu.Lock()
+	if len(a.accounts) >= maxCachedEntries {
+		a.sweep(now)
+	}
+	a.accounts[userID] = cachedAccount{state: account, expires: now.Add(a.cacheTTL)}
+	a.mu.Unlock()
+
+	return account, account.verify(issuedAt)
+}
+
+// sessionLive reports whether a session is still signed in and marks it seen.
+func (a *Authenticator) sessionLive(sessionID, userID int64) (bool, error) {
+	now := time.Now()
+
+	a.mu.Lock()
+	cached, ok := a.sessions[sessionID]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.live, nil
+	}
+
+	result, err := a.db.Exec(`
+		UPDATE sessions SET last_seen_at = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, now.UTC(), sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	live := affected > 0
+
+	a.mu.Lock()
+	if len(a.sessions) >= maxCachedEntries {
+		a.sweep(now)
+	}
+	a.sessions[sessionID] = cachedSession{userID: userID, live: live, expires: now.Add(a.cacheTTL)}
+	a.mu.Unlock()
+
+	return live, nil
+}
+
+// sweep removes expired entries. The caller holds a.mu.
+func (a *Authenticator) sweep(now time.Time) {
+	for id, account := range a.accounts {
+		if !now.Before(account.expires) {
+			delete(a.accounts, id)
+		}
+	}
+	for id, session := range a.sessions {
+		if !now.Before(session.expires) {
+			delete(a.sessions, id)
+		}
+	}
+}
@@ -25,23 +25,27 @@ func Logger(logger *zap.Logger) gin.HandlerFunc {
 		statusCode := c.Writer.Status()
 		errorMessage := c.Errors.ByType(gin.ErrorTypePrivate).String()
 
-		if errorMessage != "" {
-			logger.Error("Request failed",
-				zap.Int("status", statusCode),
-				zap.Duration("latency", latency),
-				zap.String("client_ip", clientIP),
-				zap.String("method", method),
-				zap.String("path", path),
-				zap.String("error", errorMessage),
+		fields := []zap.Field{
+			zap.Int("status", statusCode),
+			zap.Duration("latency", latency),
+			zap.String("client_ip", clientIP),
+			zap.String("method", method),
+			zap.String("path", path),
+		}
+
+		// Requests made while impersonating are attributed to the
+		// administrator as well as to the user.
+		if impersonatorID := c.GetInt64("impersonator_id"); impersonatorID != 0 {
+			fields = append(fields,
+				zap.Int64("user_id", c.GetInt64("user_id")),
+				zap.Int64("impersonator_id", impersonatorID),
 			)
+		}
+
+		if errorMessage != "" {
+			logger.Error("Request failed", append(fields, zap.String("error", errorMessage))...)
 		} else {
-			logger.Info("Request processed",
-				zap.Int("status", statusCode),
-				zap.Duration("latency", latency),
-				zap.String("client_ip", clientIP),
-				zap.String("method", method),
-				zap.String("path", path),
-			)
+			logger.Info("Request processed", fields...)
 		}
 	}
 }
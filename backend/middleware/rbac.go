@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/prem0x01/Blogy/utils"
+)
+
+// RequirePermission lets a request through only when the caller's role
+// grants permission.
+func RequirePermission(permission policy.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Can(c.GetString("role"), permission) {
+			utils.ErrorResponse(c, http.StatusForbidden, "Insufficient permissions")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail refuses callers with unverified addresses when enabled.
+func RequireVerifiedEmail(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled && !c.GetBool("email_verified") {
+			utils.ErrorResponse(c, http.StatusForbidden, "Please verify your email address first")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
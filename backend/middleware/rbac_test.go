@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prem0x01/Blogy/models"
+	"github.com/prem0x01/Blogy/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// serve runs middleware behind a stub that sets the caller's context keys,
+// and reports the status the request ends with.
+func serve(caller gin.H, middleware gin.HandlerFunc) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		for key, value := range caller {
+			c.Set(key, value)
+		}
+		c.Next()
+	}, middleware, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	return w.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       interface{}
+		permission policy.Permission
+		want       int
+	}{
+		{"author creates posts", models.RoleAuthor, policy.CreatePosts, http.StatusOK},
+		{"user cannot create posts", models.RoleUser, policy.CreatePosts, http.StatusForbidden},
+		{"moderator moderates", models.RoleModerator, policy.ModerateComments, http.StatusOK},
+		{"moderator cannot manage users", models.RoleModerator, policy.ManageUsers, http.StatusForbidden},
+		{"admin manages users", models.RoleAdmin, policy.ManageUsers, http.StatusOK},
+		{"unknown role", "root", policy.CreatePosts, http.StatusForbidden},
+		{"no role", nil, policy.CreatePosts, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := gin.H{}
+			if tt.role != nil {
+				caller["role"] = tt.role
+			}
+			assert.Equal(t, tt.want, serve(caller, RequirePermission(tt.permission)))
+		})
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		caller  gin.H
+		want    int
+	}{
+		{"verified", true, gin.H{"email_verified": true}, http.StatusOK},
+		{"unverified", true, gin.H{"email_verified": false}, http.StatusForbidden},
+		{"unknown", true, gin.H{}, http.StatusForbidden},
+		{"disabled", false, gin.H{"email_verified": false}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, serve(tt.caller, RequireVerifiedEmail(tt.enabled)))
+		})
+	}
+}
@@ -6,17 +6,87 @@ import (
 	"github.com/prem0x01/Blogy/utils"
 )
 
+const (
+	CommentStatusApproved = "approved"
+	CommentStatusPending  = "pending"
+	CommentStatusRejected = "rejected"
+)
+
+// Moderation modes decide whether a new comment goes live straight away.
+// They apply site-wide and can be overridden per post.
+const (
+	CommentModeOpen          = "open"
+	CommentModeHoldFirstTime = "hold_first_time"
+	CommentModeHoldAll       = "hold_all"
+	CommentModeClosed        = "closed"
+)
+
+func ValidCommentMode(mode string) bool {
+	switch mode {
+	case CommentModeOpen, CommentModeHoldFirstTime, CommentModeHoldAll, CommentModeClosed:
+		return true
+	}
+	return false
+}
+
+// DeletedCommentContent stands in for a deleted comment that still has
+// replies, so the thread around it stays readable.
+const DeletedCommentContent = "[deleted]"
+
 type Comment struct {
+	ID         int64      `json:"id" db:"id"`
+	PostID     int64      `json:"post_id" db:"post_id"`
+	UserID     int64      `json:"user_id" db:"user_id"`
+	ParentID   *int64     `json:"parent_id" db:"parent_id"`
+	Content    string     `json:"content" db:"content" validate:"required,min=1,max=1000"`
+	Status     string     `json:"status" db:"status"`
+	Deleted    bool       `json:"deleted" db:"-"`
+	Edited     bool       `json:"edited" db:"-"`
+	LikeCount  int64      `json:"like_count" db:"-"`
+	LikedByMe  bool       `json:"liked_by_me" db:"-"`
+	Author     *User      `json:"author,omitempty" db:"-"`
+	ReplyCount int64      `json:"reply_count" db:"-"`
+	SpamScore  *float64   `json:"spam_score,omitempty" db:"spam_score"`
+	Replies    []*Comment `json:"replies,omitempty" db:"-"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// CommentRevision is a version of a comment's content that was replaced by
+// an edit.
+type CommentRevision struct {
 	ID        int64     `json:"id" db:"id"`
-	PostID    int64     `json:"post_id" db:"post_id"`
-	UserID    int64     `json:"user_id" db:"user_id"`
-	Content   string    `json:"content" db:"content" validate:"required,min=1,max=1000"`
-	Author    *User     `json:"author,omitempty" db:"-"`
+	CommentID int64     `json:"comment_id" db:"comment_id"`
+	Content   string    `json:"content" db:"content"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 type CommentInput struct {
-	Content string `json:"content" validate:"required,min=1,max=1000"`
+	Content  string `json:"content" validate:"required,min=1,max=1000"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+// CommentSettingsInput updates a post's comment settings. Fields left out
+// are unchanged; an empty mode reverts to the site-wide default.
+type CommentSettingsInput struct {
+	Mode   *string `json:"mode"`
+	Locked *bool   `json:"locked"`
+}
+
+type ModerationInput struct {
+	CommentIDs []int64 `json:"comment_ids" validate:"required,min=1,max=100"`
+	Action     string  `json:"action" validate:"required,oneof=approve reject"`
+}
+
+// MarkDeleted hides the content and author of a comment that was deleted
+// while it had replies.
+func (c *Comment) MarkDeleted() {
+	c.Deleted = true
+	c.UserID = 0
+	c.Content = DeletedCommentContent
+	c.Author = nil
+	c.Edited = false
+	c.UpdatedAt = nil
 }
 
 func (c *Comment) Validate() error {
@@ -6,23 +6,45 @@ import (
 	"github.com/prem0x01/Blogy/utils"
 )
 
+const (
+	PostStatusDraft     = "draft"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
 type Post struct {
-	ID        int64     `json:"id" db:"id"`
-	UserID    int64     `json:"user_id" db:"user_id"`
-	Title     string    `json:"title" db:"title" validate:"required,min=3,max=200"`
-	Content   string    `json:"content" db:"content" validate:"required,min=10"`
-	Slug      string    `json:"slug" db:"slug"`
-	Status    string    `json:"status" db:"status"`
-	Views     int       `json:"views" db:"views"`
-	Author    *User     `json:"author,omitempty" db:"-"`
-	Comments  []Comment `json:"comments,omitempty" db:"-"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID             int64      `json:"id" db:"id"`
+	UserID         int64      `json:"user_id" db:"user_id"`
+	Title          string     `json:"title" db:"title" validate:"required,min=3,max=200"`
+	Content        string     `json:"content" db:"content" validate:"required,min=10,max=100000"`
+	ContentHTML    string     `json:"content_html" db:"content_html"`
+	Slug           string     `json:"slug" db:"slug"`
+	Status         string     `json:"status" db:"status"`
+	PublishAt      *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	PublishedAt    *time.Time `json:"published_at,omitempty" db:"published_at"`
+	Views          int        `json:"views" db:"views"`
+	Version        int64      `json:"version" db:"version"`
+	CommentMode    string     `json:"comment_mode,omitempty" db:"comment_mode"`
+	CommentsLocked bool       `json:"comments_locked" db:"comments_locked"`
+	Tags           []string   `json:"tags" db:"-"`
+	LikeCount      int64      `json:"like_count" db:"-"`
+	LikedByMe      bool       `json:"liked_by_me" db:"-"`
+	Author         *User      `json:"author,omitempty" db:"-"`
+	Comments       []Comment  `json:"comments,omitempty" db:"-"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type PostInput struct {
-	Title   string `json:"title" validate:"required,min=3,max=200"`
-	Content string `json:"content" validate:"required,min=10"`
+	Title     string     `json:"title" validate:"required,min=3,max=200"`
+	Content   string     `json:"content" validate:"required,min=10,max=100000"`
+	Tags      []string   `json:"tags" validate:"omitempty,max=10,dive,max=30"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft published archived"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+type RenderInput struct {
+	Content string `json:"content" validate:"max=100000"`
 }
 
 func (p *Post) Validate() error {
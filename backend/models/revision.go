@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type PostRevision struct {
+	ID        int64     `json:"id" db:"id"`
+	PostID    int64     `json:"post_id" db:"post_id"`
+	Revision  int       `json:"revision" db:"revision"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	Title     string    `json:"title" db:"title"`
+	Content   string    `json:"content,omitempty" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type RevisionDiff struct {
+	PostID    int64  `json:"post_id"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	FromTitle string `json:"from_title"`
+	ToTitle   string `json:"to_title"`
+	Diff      string `json:"diff"`
+}
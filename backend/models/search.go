@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type SearchResult struct {
+	PostID    int64     `json:"post_id"`
+	UserID    int64     `json:"user_id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Author    string    `json:"author"`
+	Highlight string    `json:"highlight"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+const MaxTagLength = 30
+
+type Tag struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	PostCount int64     `json:"post_count" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type TagInput struct {
+	Name string `json:"name" validate:"required,max=30"`
+}
+
+// NormalizeTag folds a tag to its canonical form so that "Go", " go " and
+// "GO" all name the same tag. Whitespace and underscores become hyphens and
+// anything that is not a letter, digit or one of "-+#." is dropped, which
+// keeps names like "c++" and "node.js" intact.
+func NormalizeTag(name string) string {
+	var b strings.Builder
+	lastHyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '+' || r == '#' || r == '.':
+			b.WriteRune(r)
+			lastHyphen = false
+		case r == '-' || r == '_' || unicode.IsSpace(r):
+			if !lastHyphen && b.Len() > 0 {
+				b.WriteRune('-')
+				lastHyphen = true
+			}
+		}
+	}
+
+	normalized := strings.TrimRight(b.String(), "-")
+	if runes := []rune(normalized); len(runes) > MaxTagLength {
+		normalized = strings.TrimRight(string(runes[:MaxTagLength]), "-")
+	}
+	return normalized
+}
+
+// NormalizeTags normalizes a list of tag names, dropping empty and
+// duplicate entries while keeping the original order.
+func NormalizeTags(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
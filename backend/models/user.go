@@ -3,7 +3,29 @@ package models
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/prem0x01/Blogy/passhash"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAuthor    = "author"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleAuthor, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Account states, derived from banned_at and suspended_until.
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended"
+	AccountBanned    = "banned"
 )
 
 type User struct {
@@ -11,37 +33,150 @@ type User struct {
 	Username     string    `json:"username" db:"username" validate:"required,username"`
 	Email        string    `json:"email" db:"email" validate:"required,email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         string    `json:"role" db:"role"`
 	Bio          string    `json:"bio" db:"bio"`
 	AvatarURL    string    `json:"avatar_url" db:"avatar_url"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+}
+
+// UserProfile is the public view of a user.
+type UserProfile struct {
+	ID             int64     `json:"id"`
+	Username       string    `json:"username"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	PostCount      int64     `json:"post_count"`
+	FollowedByMe   bool      `json:"followed_by_me"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AccountDetails is the administrative view of a user.
+type AccountDetails struct {
+	ID              int64      `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	Status          string     `json:"status"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+	BannedAt        *time.Time `json:"banned_at,omitempty"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	TokensRevokedAt *time.Time `json:"tokens_revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SetStatus derives Status from the ban and suspension as of now.
+func (a *AccountDetails) SetStatus(now time.Time) {
+	switch {
+	case a.BannedAt != nil:
+		a.Status = AccountBanned
+	case a.SuspendedUntil != nil && a.SuspendedUntil.After(now):
+		a.Status = AccountSuspended
+	default:
+		a.Status = AccountActive
+	}
+}
+
+// AuditEntry records one administrative action on an account.
+type AuditEntry struct {
+	ID            int64     `json:"id"`
+	AdminID       int64     `json:"admin_id"`
+	AdminUsername string    `json:"admin_username"`
+	Action        string    `json:"action"`
+	TargetUserID  int64     `json:"target_user_id"`
+	Details       string    `json:"details"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SuspendInput struct {
+	Until  time.Time `json:"until" validate:"required"`
+	Reason string    `json:"reason" validate:"max=500"`
+}
+
+type BanInput struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type RoleInput struct {
+	Role string `json:"role" validate:"required,oneof=user author moderator admin"`
+}
+
+// ImpersonationInput asks for a short-lived token acting as another user.
+// The reason ends up in the audit log.
+type ImpersonationInput struct {
+	Reason string `json:"reason" validate:"required,min=10,max=500"`
+}
+
+// TwoFactorCodeInput carries a code from an authenticator app or, where
+// allowed, a recovery code.
+type TwoFactorCodeInput struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// LoginInput signs in with a username or an email address.
+type LoginInput struct {
+	Identifier string `json:"identifier" validate:"required,max=254"`
+	Password   string `json:"password" validate:"required,max=128"`
+}
+
+// TwoFactorLoginInput completes a login that was answered with a
+// two-factor challenge.
+type TwoFactorLoginInput struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=128,password"`
+}
+
+// ChangeEmailInput starts moving an account to a new address. The
+// password is asked for again so a stolen access token cannot take the
+// account over.
+type ChangeEmailInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=128"`
 }
 
 type UserInput struct {
 	Username string `json:"username" validate:"required,username"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,password"`
+	Password string `json:"password" validate:"required,max=128,password"`
 }
 
-func (u *User) SetPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// SetPassword stores a hash of password made by hasher.
+func (u *User) SetPassword(hasher passhash.Hasher, password string) error {
+	hash, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
-	u.PasswordHash = string(hash)
+	u.PasswordHash = hash
 	return nil
 }
 
+// CheckPassword reports whether password is the user's, whichever
+// algorithm their hash was made with.
 func (u *User) CheckPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
-	return err == nil
+	ok, err := passhash.Verify(password, u.PasswordHash)
+	return err == nil && ok
 }
 
 func (u *User) Sanitize() map[string]interface{} {
 	return map[string]interface{}{
-		"id":         u.ID,
-		"username":   u.Username,
-		"email":      u.Email,
-		"created_at": u.CreatedAt,
+		"id":             u.ID,
+		"username":       u.Username,
+		"email":          u.Email,
+		"role":           u.Role,
+		"email_verified": u.EmailVerifiedAt != nil,
+		"created_at":     u.CreatedAt,
 	}
 }
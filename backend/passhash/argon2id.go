@@ -0,0 +1,156 @@
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var b64 = base64.RawStdEncoding
+
+// Argon2idParams are the cost parameters of Argon2id. Memory is in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams are the second recommended option of RFC 9106.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// lanes limits how much Argon2 work runs at once. Each computation takes
+// as many lanes as its parallelism out of one per CPU, so that a burst of
+// logins queues up instead of running every hash at once, each holding its
+// memory and all of them fighting over the same cores.
+type lanes struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	free  int
+	total int
+}
+
+func newLanes(total int) *lanes {
+	l := &lanes{free: total, total: total}
+	l.freed = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits for n lanes, or all of them if there are fewer, and
+// returns how many it took.
+func (l *lanes) acquire(n int) int {
+	n = max(1, min(n, l.total))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.free < n {
+		l.freed.Wait()
+	}
+	l.free -= n
+	return n
+}
+
+func (l *lanes) release(n int) {
+	l.mu.Lock()
+	l.free += n
+	l.mu.Unlock()
+	l.freed.Broadcast()
+}
+
+var argon2Lanes = newLanes(runtime.NumCPU())
+
+// argon2Key is argon2.IDKey, run when there are lanes free for it.
+func argon2Key(password, salt []byte, p Argon2idParams) []byte {
+	n := argon2Lanes.acquire(int(p.Parallelism))
+	defer argon2Lanes.release(n)
+	return argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+}
+
+type argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2id returns a Hasher that uses Argon2id with params.
+func NewArgon2id(params Argon2idParams) Hasher {
+	return argon2idHasher{params: params}
+}
+
+func (h argon2idHasher) Hash(password string) (string, error) {
+	p := h.params
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2Key([]byte(password), salt, p)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (h argon2idHasher) NeedsRehash(encoded string) bool {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.Memory < h.params.Memory ||
+		p.Iterations < h.params.Iterations ||
+		p.Parallelism < h.params.Parallelism ||
+		uint32(len(salt)) < h.params.SaltLength ||
+		uint32(len(key)) < h.params.KeyLength
+}
+
+func (h argon2idHasher) MaxLength() int {
+	return 0
+}
+
+func verifyArgon2id(password, encoded string) (bool, error) {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2Key([]byte(password), salt, p)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=…,t=…,p=…$salt$key.
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
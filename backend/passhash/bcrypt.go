@@ -0,0 +1,59 @@
+package passhash
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt returns a Hasher that uses bcrypt at cost. Bcrypt only looks
+// at the first 72 bytes of a password and is kept for the hashes made
+// before Argon2id was; new installations should not choose it.
+func NewBcrypt(cost int) Hasher {
+	return bcryptHasher{cost: cost}
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	return string(hash), err
+}
+
+// MaxLength is where bcrypt stops reading; GenerateFromPassword refuses
+// anything longer rather than ignore the rest.
+func (h bcryptHasher) MaxLength() int {
+	return 72
+}
+
+func (h bcryptHasher) NeedsRehash(encoded string) bool {
+	if !isBcrypt(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.cost
+}
+
+// isBcrypt reports whether encoded is in bcrypt's own modular crypt
+// format, which predates PHC strings.
+func isBcrypt(encoded string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyBcrypt(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	switch err {
+	case nil:
+		return true, nil
+	case bcrypt.ErrMismatchedHashAndPassword:
+		return false, nil
+	}
+	return false, ErrMalformedHash
+}
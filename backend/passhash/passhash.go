@@ -0,0 +1,38 @@
+// Package passhash hashes passwords for storage. Hashes are PHC strings,
+// such as $argon2id$v=19$m=65536,t=3,p=4$salt$hash, which carry their
+// algorithm and parameters, so Verify works whatever they were made with
+// and NeedsRehash can tell which ones should be replaced.
+package passhash
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Hasher makes new hashes with one algorithm and one set of parameters.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether encoded was made with another algorithm
+	// or with weaker parameters than the hasher would use.
+	NeedsRehash(encoded string) bool
+	// MaxLength is the longest password in bytes the hasher takes, or 0
+	// when there is no limit.
+	MaxLength() int
+}
+
+// Verify reports whether password matches encoded, which may be any
+// supported hash.
+func Verify(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(password, encoded)
+	case isBcrypt(encoded):
+		return verifyBcrypt(password, encoded)
+	}
+	return false, ErrUnknownAlgorithm
+}
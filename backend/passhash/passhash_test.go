@@ -0,0 +1,112 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testParams = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2id(t *testing.T) {
+	hasher := NewArgon2id(testParams)
+	hash, err := hasher.Hash("s3cret!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+
+	ok, err := Verify("s3cret!", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Verify("s3cret?", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Salts are random, so the same password hashes differently.
+	again, err := hasher.Hash("s3cret!")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again)
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	stronger := testParams
+	stronger.Iterations = 2
+	assert.True(t, NewArgon2id(stronger).NeedsRehash(hash))
+	stronger = testParams
+	stronger.Memory = 2048
+	assert.True(t, NewArgon2id(stronger).NeedsRehash(hash))
+}
+
+func TestVerifyReferenceHash(t *testing.T) {
+	// From the test vectors of the argon2 reference implementation.
+	const hash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	ok, err := Verify("password", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("s3cret!"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, err := Verify("s3cret!", string(legacy))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Verify("wrong", string(legacy))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Bcrypt hashes are legacy for an Argon2id hasher, and weak for a
+	// bcrypt hasher with a higher cost.
+	assert.True(t, NewArgon2id(testParams).NeedsRehash(string(legacy)))
+	assert.True(t, NewBcrypt(bcrypt.MinCost+1).NeedsRehash(string(legacy)))
+	assert.False(t, NewBcrypt(bcrypt.MinCost).NeedsRehash(string(legacy)))
+
+	hash, err := NewArgon2id(testParams).Hash("s3cret!")
+	require.NoError(t, err)
+	assert.True(t, NewBcrypt(bcrypt.MinCost).NeedsRehash(hash))
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"x",
+		"plaintext",
+		"$argon2i$v=19$m=1024,t=1,p=1$c29tZXNhbHQ$aGFzaA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c29tZXNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=0,p=1$c29tZXNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=0$c29tZXNhbHQ$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHQ$",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$aGFzaA",
+		"$2a$10$short",
+	} {
+		ok, err := Verify("password", hash)
+		assert.Error(t, err, hash)
+		assert.False(t, ok, hash)
+	}
+}
+
+func TestLanes(t *testing.T) {
+	l := newLanes(4)
+
+	// Asking for more lanes than there are takes them all rather than
+	// waiting forever.
+	assert.Equal(t, 4, l.acquire(8))
+	l.release(4)
+
+	assert.Equal(t, 3, l.acquire(3))
+	acquired := make(chan int)
+	go func() { acquired <- l.acquire(2) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired lanes that were taken")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.release(3)
+	assert.Equal(t, 2, <-acquired)
+	assert.Equal(t, 1, l.acquire(0))
+}
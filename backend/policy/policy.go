@@ -0,0 +1,66 @@
+// Package policy decides what a user may do based on their role and their
+// relationship to the resource at hand.
+package policy
+
+import "github.com/prem0x01/Blogy/models"
+
+type Permission string
+
+const (
+	CreatePosts      Permission = "posts:create"
+	ManageAnyPost    Permission = "posts:manage_any"
+	ModerateComments Permission = "comments:moderate"
+	DeleteAnyComment Permission = "comments:delete_any"
+	ManageTags       Permission = "tags:manage"
+	ManageUsers      Permission = "users:manage"
+	ImpersonateUsers Permission = "users:impersonate"
+)
+
+// Each role has the permissions of the roles below it plus its own.
+var rolePermissions = map[string][]Permission{
+	models.RoleUser:      {},
+	models.RoleAuthor:    {CreatePosts},
+	models.RoleModerator: {CreatePosts, ModerateComments, DeleteAnyComment, ManageTags},
+	models.RoleAdmin:     {CreatePosts, ModerateComments, DeleteAnyComment, ManageTags, ManageAnyPost, ManageUsers, ImpersonateUsers},
+}
+
+// Can reports whether role grants permission. Unknown roles grant nothing.
+func Can(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor is the signed-in user a decision is made for.
+type Actor struct {
+	UserID int64
+	Role   string
+}
+
+func (a Actor) Can(permission Permission) bool {
+	return Can(a.Role, permission)
+}
+
+// CanManagePost covers editing, publishing, deleting and the revision
+// history of a post. Owners keep control of their posts even if their role
+// no longer lets them write new ones.
+func CanManagePost(a Actor, ownerID int64) bool {
+	return a.UserID == ownerID || a.Can(ManageAnyPost)
+}
+
+func CanDeleteComment(a Actor, authorID int64) bool {
+	return a.UserID == authorID || a.Can(DeleteAnyComment)
+}
+
+// CanModerateComments reports whether a can approve or reject comments on
+// a post. Post owners moderate their own posts.
+func CanModerateComments(a Actor, postOwnerID int64) bool {
+	return a.UserID == postOwnerID || a.Can(ModerateComments)
+}
+
+func CanViewCommentHistory(a Actor, authorID, postOwnerID int64) bool {
+	return a.UserID == authorID || CanModerateComments(a, postOwnerID)
+}
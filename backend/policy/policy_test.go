@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/prem0x01/Blogy/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCan(t *testing.T) {
+	assert.False(t, Can(models.RoleUser, CreatePosts))
+	assert.True(t, Can(models.RoleAuthor, CreatePosts))
+	assert.False(t, Can(models.RoleAuthor, DeleteAnyComment))
+	assert.True(t, Can(models.RoleModerator, DeleteAnyComment))
+	assert.False(t, Can(models.RoleModerator, ManageAnyPost))
+	assert.True(t, Can(models.RoleAdmin, ManageAnyPost))
+	assert.True(t, Can(models.RoleAdmin, ManageUsers))
+	assert.False(t, Can("", CreatePosts))
+	assert.False(t, Can("superuser", CreatePosts))
+}
+
+func TestOwnershipRules(t *testing.T) {
+	owner := Actor{UserID: 1, Role: models.RoleUser}
+	stranger := Actor{UserID: 2, Role: models.RoleAuthor}
+	moderator := Actor{UserID: 3, Role: models.RoleModerator}
+	admin := Actor{UserID: 4, Role: models.RoleAdmin}
+
+	// Demoted owners keep control of what they already wrote.
+	assert.True(t, CanManagePost(owner, 1))
+	assert.False(t, CanManagePost(stranger, 1))
+	assert.False(t, CanManagePost(moderator, 1))
+	assert.True(t, CanManagePost(admin, 1))
+
+	assert.True(t, CanDeleteComment(owner, 1))
+	assert.False(t, CanDeleteComment(stranger, 1))
+	assert.True(t, CanDeleteComment(moderator, 1))
+
+	assert.True(t, CanModerateComments(owner, 1))
+	assert.False(t, CanModerateComments(stranger, 1))
+	assert.True(t, CanModerateComments(moderator, 1))
+
+	assert.True(t, CanViewCommentHistory(stranger, 2, 1))
+	assert.False(t, CanViewCommentHistory(stranger, 5, 1))
+	assert.True(t, CanViewCommentHistory(moderator, 5, 1))
+}
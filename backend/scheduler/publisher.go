@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Publisher flips scheduled drafts to published once their publish_at time
+// has passed. All state lives in the posts table, so posts that fell due
+// while the server was down go out on the first run after a restart.
+type Publisher struct {
+	db       *sql.DB
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+func NewPublisher(db *sql.DB, interval time.Duration, logger *zap.Logger) *Publisher {
+	return &Publisher{
+		db:       db,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run publishes due posts immediately and then on every tick until ctx is
+// cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.publish(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context) {
+	published, err := p.PublishDue(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logger.Error("Failed to publish scheduled posts", zap.Error(err))
+		}
+		return
+	}
+
+	if published > 0 {
+		p.logger.Info("Published scheduled posts", zap.Int64("count", published))
+	}
+}
+
+// PublishDue publishes every scheduled draft whose publish_at is at or
+// before now and returns how many were published. They count as published
+// at their scheduled time, however late the publisher gets to them.
+func (p *Publisher) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	result, err := p.db.ExecContext(ctx, `
+		UPDATE posts SET status = 'published', published_at = publish_at, version = version + 1
+		WHERE status = 'draft' AND publish_at IS NOT NULL AND publish_at <= ?
+	`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
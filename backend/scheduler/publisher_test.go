@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prem0x01/Blogy/database"
+	"github.com/prem0x01/Blogy/database/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPublishDue(t *testing.T) {
+	db, err := database.NewDatabase(":memory:", &database.Config{MaxOpenConns: 1, MaxIdleConns: 1})
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, migrations.RunMigrations(db.DB))
+
+	_, err = db.Exec("INSERT INTO users (username, email, password_hash) VALUES ('editor', 'editor@example.com', 'x')")
+	require.NoError(t, err)
+
+	now := time.Now()
+	posts := []struct {
+		slug      string
+		status    string
+		publishAt interface{}
+	}{
+		{"due", "draft", now.Add(-time.Minute).UTC()},
+		{"later", "draft", now.Add(time.Hour).UTC()},
+		{"plain-draft", "draft", nil},
+		{"archived", "archived", now.Add(-time.Minute).UTC()},
+	}
+	for _, p := range posts {
+		_, err := db.Exec(`
+			INSERT INTO posts (user_id, title, content, slug, status, publish_at)
+			VALUES (1, 'Title', 'Some content here.', ?, ?, ?)
+		`, p.slug, p.status, p.publishAt)
+		require.NoError(t, err)
+	}
+
+	publisher := NewPublisher(db.DB, time.Minute, zap.NewNop())
+
+	published, err := publisher.PublishDue(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), published)
+
+	statusOf := func(slug string) string {
+		var status string
+		require.NoError(t, db.QueryRow("SELECT status FROM posts WHERE slug = ?", slug).Scan(&status))
+		return status
+	}
+	assert.Equal(t, "published", statusOf("due"))
+	assert.Equal(t, "draft", statusOf("later"))
+	assert.Equal(t, "draft", statusOf("plain-draft"))
+	assert.Equal(t, "archived", statusOf("archived"))
+
+	published, err = publisher.PublishDue(context.Background(), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), published)
+	assert.Equal(t, "published", statusOf("later"))
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	db, err := database.NewDatabase(":memory:", &database.Config{MaxOpenConns: 1, MaxIdleConns: 1})
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, migrations.RunMigrations(db.DB))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewPublisher(db.DB, time.Hour, zap.NewNop()).Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publisher did not stop after cancel")
+	}
+}
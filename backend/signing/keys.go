@@ -0,0 +1,265 @@
+// Package signing signs and verifies the JWTs Blogy issues.
+//
+// Tokens are signed with EdDSA or RS256 keys read from a directory, and
+// name the key in their kid header so that other services can verify them
+// against the public keys published as a JWKS. Without a key directory
+// tokens are signed HS256 with a shared secret, as they used to be.
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key accepted for signing or verification.
+const minRSABits = 2048
+
+// legacyTokenLifetime is the longest a token signed with the shared secret
+// lived: an access token's hour.
+const legacyTokenLifetime = time.Hour
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrNoSigningKey   = errors.New("no private key to sign with")
+	errMethodMismatch = errors.New("token algorithm does not match its key")
+	errLegacyToken    = errors.New("token names no key and was not issued before the switch")
+)
+
+// Keys holds the key new tokens are signed with and every key tokens are
+// still accepted from.
+type Keys struct {
+	current *key
+	byID    map[string]*key
+
+	// secret verifies tokens that name no key: all tokens when there is no
+	// key directory, and otherwise those issued before there was one, at
+	// secretCutoff.
+	secret       []byte
+	secretCutoff time.Time
+}
+
+type key struct {
+	id      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  crypto.PublicKey
+}
+
+// NewHMAC signs and verifies tokens HS256 with secret alone.
+func NewHMAC(secret string) *Keys {
+	return &Keys{byID: map[string]*key{}, secret: []byte(secret)}
+}
+
+// Load reads the keys in dir. Each *.pem file holds one key and its name,
+// without the extension, is the key's ID: a PKCS#8 or PKCS#1 private key
+// can sign, a PKIX public key only verifies, which is how a retired key is
+// kept until the tokens it signed have expired. New tokens are signed with
+// currentID or, when that is empty, the private key whose ID sorts last,
+// so naming keys by the date they were made rotates them in order.
+//
+// Tokens without a kid are verified with secret, if it is set, so that
+// those signed before the switch keep working until they expire. Only
+// tokens issued before the keys were loaded, and living no longer than
+// tokens signed with the secret did, are accepted, so the secret stops
+// verifying anything soon after the switch.
+func Load(dir, currentID, secret string) (*Keys, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := &Keys{byID: map[string]*key{}}
+	if secret != "" {
+		keys.secret = []byte(secret)
+		keys.secretCutoff = time.Now()
+	}
+
+	for _, path := range paths {
+		k, err := readKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys.byID[k.id] = k
+		if k.private != nil && currentID == "" {
+			keys.current = k
+		}
+	}
+
+	if currentID != "" {
+		keys.current = keys.byID[currentID]
+		if keys.current == nil {
+			return nil, fmt.Errorf("signing key %q: %w", currentID, ErrUnknownKey)
+		}
+	}
+	if keys.current == nil || keys.current.private == nil {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNoSigningKey)
+	}
+	return keys, nil
+}
+
+func readKey(path string) (*key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	k := &key{id: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, parsed, parsed.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, parsed
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, parsed, &parsed.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, parsed
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if public, ok := k.public.(*rsa.PublicKey); ok && public.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key is shorter than %d bits", minRSABits)
+	}
+	return k, nil
+}
+
+// Sign signs claims with the current key.
+func (k *Keys) Sign(claims jwt.Claims) (string, error) {
+	if k.current == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(k.current.method, claims)
+	token.Header["kid"] = k.current.id
+	return token.SignedString(k.current.private)
+}
+
+// Parse verifies a token against the key it names and returns it with
+// jwt.MapClaims.
+func (k *Keys) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, k.verificationKey)
+}
+
+func (k *Keys) verificationKey(token *jwt.Token) (interface{}, error) {
+	id, ok := token.Header["kid"].(string)
+	if !ok {
+		if k.secret == nil {
+			return nil, ErrUnknownKey
+		}
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errMethodMismatch
+		}
+		if !k.secretCutoff.IsZero() && !issuedBefore(token, k.secretCutoff) {
+			return nil, errLegacyToken
+		}
+		return k.secret, nil
+	}
+
+	key, ok := k.byID[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errMethodMismatch
+	}
+	return key.public, nil
+}
+
+// issuedBefore reports whether token says it was issued by cutoff and
+// expires within legacyTokenLifetime of that. Anyone holding the secret
+// could backdate a token, but not make one that outlives the window.
+func issuedBefore(token *jwt.Token, cutoff time.Time) bool {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || issuedAt.After(cutoff) {
+		return false
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	return err == nil && expiresAt != nil && expiresAt.Sub(issuedAt.Time) <= legacyTokenLifetime
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+
+	// RSA keys
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+}
+
+// JWKS is the body of /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every key tokens are accepted from. The
+// shared secret is of course left out.
+func (k *Keys) JWKS() JWKS {
+	ids := make([]string, 0, len(k.byID))
+	for id := range k.byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key := k.byID[id]
+		jwk := JWK{KeyID: id, Algorithm: key.method.Alg(), Use: "sig"}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.Modulus = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
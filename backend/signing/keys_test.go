@@ -0,0 +1,141 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir, id, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600))
+}
+
+func writeEd25519Key(t *testing.T, dir, id string) ed25519.PublicKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	writeKey(t, dir, id, "PRIVATE KEY", der)
+	return public
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writeKey(t, dir, "2026-01", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	writeEd25519Key(t, dir, "2026-02")
+
+	old, err := Load(dir, "", "legacy-secret")
+	require.NoError(t, err)
+	oldToken, err := old.Sign(jwt.MapClaims{"user_id": 1})
+	require.NoError(t, err)
+
+	// Rotation: a new key takes over signing, and the previous one is kept
+	// as a public key so that what it signed still verifies.
+	writeEd25519Key(t, dir, "2026-03")
+	public, err := x509.MarshalPKIXPublicKey(old.current.public)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(dir, "2026-02.pem")))
+	writeKey(t, dir, "2026-02", "PUBLIC KEY", public)
+
+	keys, err := Load(dir, "", "legacy-secret")
+	require.NoError(t, err)
+	token, err := keys.Sign(jwt.MapClaims{"user_id": 2})
+	require.NoError(t, err)
+
+	parsed, err := keys.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-03", parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	parsed, err = keys.Parse(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-02", parsed.Header["kid"])
+
+	// The signing key can be chosen explicitly, but must be able to sign.
+	keys, err = Load(dir, "2026-01", "")
+	require.NoError(t, err)
+	token, err = keys.Sign(jwt.MapClaims{"user_id": 3})
+	require.NoError(t, err)
+	parsed, err = keys.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	_, err = Load(dir, "2026-02", "")
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	_, err = Load(dir, "2027-01", "")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = Load(t.TempDir(), "", "")
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+
+	set := keys.JWKS()
+	require.Len(t, set.Keys, 3)
+	assert.Equal(t, JWK{KeyType: "RSA", KeyID: "2026-01", Algorithm: "RS256", Use: "sig",
+		Modulus: set.Keys[0].Modulus, Exponent: "AQAB"}, set.Keys[0])
+	assert.Equal(t, "OKP", set.Keys[1].KeyType)
+	assert.Equal(t, "Ed25519", set.Keys[2].Curve)
+}
+
+func TestKeysRejectForgedTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current")
+	keys, err := Load(dir, "", "legacy-secret")
+	require.NoError(t, err)
+
+	// Tokens signed with the shared secret from before the switch still
+	// verify, as long as they name no key.
+	issued := time.Now().Add(-time.Minute)
+	legacySecret := NewHMAC("legacy-secret")
+	legacy, err := legacySecret.Sign(jwt.MapClaims{
+		"user_id": 1,
+		"iat":     issued.Unix(),
+		"exp":     issued.Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	_, err = keys.Parse(legacy)
+	assert.NoError(t, err)
+
+	// Tokens the secret signed after the switch, or that would outlive the
+	// tokens it used to sign, are refused, and so are undated ones.
+	for _, claims := range []jwt.MapClaims{
+		{"user_id": 1, "iat": time.Now().Add(time.Minute).Unix(), "exp": time.Now().Add(time.Hour).Unix()},
+		{"user_id": 1, "iat": issued.Unix(), "exp": issued.Add(24 * time.Hour).Unix()},
+		{"user_id": 1, "iat": issued.Unix()},
+		{"user_id": 1},
+	} {
+		token, err := legacySecret.Sign(claims)
+		require.NoError(t, err)
+		_, err = keys.Parse(token)
+		assert.ErrorIs(t, err, errLegacyToken, claims)
+	}
+
+	withKid := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 1})
+	withKid.Header["kid"] = "current"
+	forged, err := withKid.SignedString([]byte("legacy-secret"))
+	require.NoError(t, err)
+	_, err = keys.Parse(forged)
+	assert.Error(t, err)
+
+	_, err = keys.Parse(legacy + "x")
+	assert.Error(t, err)
+
+	keys, err = Load(dir, "", "")
+	require.NoError(t, err)
+	_, err = keys.Parse(legacy)
+	assert.Error(t, err)
+}
@@ -0,0 +1,224 @@
+// Package spam scores user content for spam without calling any external
+// service.
+package spam
+
+import (
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// maxTokens caps how many distinct words of a document the model looks
+	// at, which bounds the size of the lookup query.
+	maxTokens = 200
+
+	// minTrainingDocuments is how many spam and how many ham decisions the
+	// model needs before its opinion is taken into account.
+	minTrainingDocuments = 5
+
+	// minDuplicateLength keeps short stock replies such as "thanks!" from
+	// counting as duplicates.
+	minDuplicateLength = 20
+
+	duplicateWindow = 7 * 24 * time.Hour
+)
+
+// Classifier is the built-in spam checker. Its score is the strongest of
+// three signals: links, a naive Bayes model trained from moderation
+// decisions, and how often the same text was seen recently.
+type Classifier struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+func NewClassifier(db *sql.DB) *Classifier {
+	return &Classifier{db: db, now: time.Now}
+}
+
+// Check scores content posted by userID between 0 (clean) and 1 (spam).
+// Content only counts towards duplicate detection once it is Recorded.
+func (c *Classifier) Check(userID int64, content string) (float64, error) {
+	score := linkScore(content)
+
+	bayes, err := c.bayesScore(content)
+	if err != nil {
+		return 0, err
+	}
+	score = math.Max(score, bayes)
+
+	duplicate, err := c.duplicateScore(userID, content)
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(score, duplicate), nil
+}
+
+// Learn trains the model with a document a moderator has marked as spam
+// or not spam. id identifies the document, such as a comment ID: teaching
+// the same document again first takes back what it taught before, so a
+// reversed decision is not counted for both labels.
+func (c *Classifier) Learn(id int64, content string, isSpam bool) error {
+	label := "ham"
+	if isSpam {
+		label = "spam"
+	}
+	tokens := tokenize(content)
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldLabel, oldTokens string
+	err = tx.QueryRow("SELECT label, tokens FROM spam_trained WHERE id = ?", id).Scan(&oldLabel, &oldTokens)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	default:
+		if err := train(tx, oldLabel, strings.Fields(oldTokens), -1); err != nil {
+			return err
+		}
+	}
+
+	if err := train(tx, label, tokens, 1); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO spam_trained (id, label, tokens) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET label = excluded.label, tokens = excluded.tokens
+	`, id, label, strings.Join(tokens, " ")); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// train adds delta to the count of label's documents and to that of each
+// of their tokens.
+func train(tx *sql.Tx, label string, tokens []string, delta int) error {
+	column := label + "_count"
+	for _, token := range tokens {
+		if _, err := tx.Exec(`
+			INSERT INTO spam_tokens (token, `+column+`) VALUES (?, MAX(?, 0))
+			ON CONFLICT(token) DO UPDATE SET `+column+` = MAX(`+column+` + ?, 0)
+		`, token, delta, delta); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO spam_documents (label, count) VALUES (?, MAX(?, 0))
+		ON CONFLICT(label) DO UPDATE SET count = MAX(count + ?, 0)
+	`, label, delta, delta)
+	return err
+}
+
+// bayesScore is the naive Bayes probability that content is spam, with
+// equal priors so that a backlog of one kind of decision does not skew it.
+// It returns 0 until the model has seen enough of both kinds.
+func (c *Classifier) bayesScore(content string) (float64, error) {
+	var spamDocs, hamDocs float64
+	err := c.db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN label = 'spam' THEN count END), 0),
+		       COALESCE(SUM(CASE WHEN label = 'ham' THEN count END), 0)
+		FROM spam_documents
+	`).Scan(&spamDocs, &hamDocs)
+	if err != nil {
+		return 0, err
+	}
+	if spamDocs < minTrainingDocuments || hamDocs < minTrainingDocuments {
+		return 0, nil
+	}
+
+	tokens := tokenize(content)
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	args := make([]interface{}, len(tokens))
+	for i, token := range tokens {
+		args[i] = token
+	}
+
+	rows, err := c.db.Query(`
+		SELECT spam_count, ham_count
+		FROM spam_tokens
+		WHERE token IN (?`+strings.Repeat(", ?", len(tokens)-1)+`)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// Sum log-likelihood ratios with Laplace smoothing. Words the model has
+	// never seen carry no information and are left out.
+	var logOdds float64
+	for rows.Next() {
+		var spamCount, hamCount float64
+		if err := rows.Scan(&spamCount, &hamCount); err != nil {
+			return 0, err
+		}
+		pSpam := (spamCount + 1) / (spamDocs + 2)
+		pHam := (hamCount + 1) / (hamDocs + 2)
+		logOdds += math.Log(pSpam) - math.Log(pHam)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return 1 / (1 + math.Exp(-logOdds)), nil
+}
+
+// Record remembers content posted by userID for duplicate detection. It is
+// called once the content has been stored, so that a failed attempt does
+// not make its retry look like a copy.
+func (c *Classifier) Record(userID int64, content string) error {
+	normalized := normalize(content)
+	if len(normalized) < minDuplicateLength {
+		return nil
+	}
+
+	now := c.now().UTC()
+	if _, err := c.db.Exec("DELETE FROM spam_fingerprints WHERE created_at < ?", now.Add(-duplicateWindow)); err != nil {
+		return err
+	}
+	_, err := c.db.Exec(
+		"INSERT INTO spam_fingerprints (hash, user_id, created_at) VALUES (?, ?, ?)",
+		fingerprint(normalized), userID, now,
+	)
+	return err
+}
+
+// duplicateScore rates content by how often the same text was posted in
+// the last week.
+func (c *Classifier) duplicateScore(userID int64, content string) (float64, error) {
+	normalized := normalize(content)
+	if len(normalized) < minDuplicateLength {
+		return 0, nil
+	}
+
+	var copies, ownCopies int
+	err := c.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(user_id = ?), 0)
+		FROM spam_fingerprints
+		WHERE hash = ? AND created_at >= ?
+	`, userID, fingerprint(normalized), c.now().UTC().Add(-duplicateWindow)).Scan(&copies, &ownCopies)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case copies >= 3:
+		return 0.95, nil
+	case ownCopies > 0:
+		// The same person pasting the same text again.
+		return 0.9, nil
+	case copies > 0:
+		return 0.6, nil
+	}
+	return 0, nil
+}
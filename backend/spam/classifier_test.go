@@ -0,0 +1,116 @@
+package spam
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prem0x01/Blogy/database"
+	"github.com/prem0x01/Blogy/database/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClassifier(t *testing.T) *Classifier {
+	t.Helper()
+
+	db, err := database.NewDatabase(":memory:", &database.Config{MaxOpenConns: 1, MaxIdleConns: 1})
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, migrations.RunMigrations(db.DB))
+
+	return NewClassifier(db.DB)
+}
+
+func TestLinkScore(t *testing.T) {
+	assert.Zero(t, linkScore("A perfectly normal comment about the post."))
+	assert.InDelta(t, 0.3, linkScore("Related reading: https://example.com/article is worth a look."), 1e-9)
+	assert.InDelta(t, 0.6, linkScore("https://spam.example"), 1e-9)
+	assert.InDelta(t, 0.95, linkScore("buy www.a.example www.b.example www.c.example www.d.example now please friends"), 1e-9)
+}
+
+func TestBayesScore(t *testing.T) {
+	c := newTestClassifier(t)
+
+	score, err := c.Check(1, "cheap pills discount casino")
+	require.NoError(t, err)
+	assert.Zero(t, score, "an untrained model has no opinion")
+
+	for i := 0; i < minTrainingDocuments; i++ {
+		require.NoError(t, c.Learn(int64(2*i+1), fmt.Sprintf("cheap pills casino bonus offer %d", i), true))
+		require.NoError(t, c.Learn(int64(2*i+2), fmt.Sprintf("great post about golang testing %d", i), false))
+	}
+
+	spam, err := c.bayesScore("casino bonus pills")
+	require.NoError(t, err)
+	ham, err := c.bayesScore("nice golang post")
+	require.NoError(t, err)
+	assert.Greater(t, spam, 0.9)
+	assert.Less(t, ham, 0.1)
+}
+
+func TestLearnReplacesDecision(t *testing.T) {
+	c := newTestClassifier(t)
+
+	counts := func(token string) (spam, ham, spamDocs, hamDocs int) {
+		t.Helper()
+		require.NoError(t, c.db.QueryRow(
+			"SELECT spam_count, ham_count FROM spam_tokens WHERE token = ?", token,
+		).Scan(&spam, &ham))
+		require.NoError(t, c.db.QueryRow(`
+			SELECT COALESCE(SUM(CASE WHEN label = 'spam' THEN count END), 0),
+			       COALESCE(SUM(CASE WHEN label = 'ham' THEN count END), 0)
+			FROM spam_documents
+		`).Scan(&spamDocs, &hamDocs))
+		return
+	}
+
+	require.NoError(t, c.Learn(1, "casino bonus", true))
+	spam, ham, spamDocs, hamDocs := counts("casino")
+	assert.Equal(t, []int{1, 0, 1, 0}, []int{spam, ham, spamDocs, hamDocs})
+
+	// Reversing the decision moves the document to the other label.
+	require.NoError(t, c.Learn(1, "casino bonus", false))
+	spam, ham, spamDocs, hamDocs = counts("casino")
+	assert.Equal(t, []int{0, 1, 0, 1}, []int{spam, ham, spamDocs, hamDocs})
+
+	// Edited content replaces what the old content taught.
+	require.NoError(t, c.Learn(1, "golang tips", false))
+	spam, ham, _, hamDocs = counts("casino")
+	assert.Equal(t, []int{0, 0, 1}, []int{spam, ham, hamDocs})
+}
+
+func TestDuplicateScore(t *testing.T) {
+	c := newTestClassifier(t)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	post := func(userID int64, content string) float64 {
+		t.Helper()
+		score, err := c.Check(userID, content)
+		require.NoError(t, err)
+		require.NoError(t, c.Record(userID, content))
+		return score
+	}
+
+	message := "Check out my amazing profile for more!"
+
+	// Content that was checked but never stored is not a copy.
+	score, err := c.Check(1, message)
+	require.NoError(t, err)
+	assert.Zero(t, score)
+	assert.Zero(t, post(1, message))
+
+	// Trivial changes to case and punctuation do not hide a copy.
+	assert.Equal(t, 0.6, post(2, "check out my AMAZING profile, for more"))
+	assert.Equal(t, 0.9, post(1, message))
+
+	// Short stock replies are never duplicates.
+	for i := 0; i < 5; i++ {
+		assert.Zero(t, post(int64(i), "Thanks!"))
+	}
+
+	// Copies older than the window are forgotten.
+	now = now.Add(duplicateWindow + time.Minute)
+	assert.Zero(t, post(3, message))
+}
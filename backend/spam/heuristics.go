@@ -0,0 +1,71 @@
+package spam
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// linkScore rates content by how many links it carries. A bare link with
+// hardly any text around it is suspicious on its own.
+func linkScore(content string) float64 {
+	links := len(linkPattern.FindAllStringIndex(content, -1))
+	if links == 0 {
+		return 0
+	}
+
+	score := 0.3 * float64(links)
+	if words := len(strings.Fields(linkPattern.ReplaceAllString(content, ""))); words < 3 {
+		score += 0.3
+	}
+	if score > 0.95 {
+		score = 0.95
+	}
+	return score
+}
+
+// normalize folds case, punctuation and spacing so that trivially altered
+// copies of a message compare equal.
+func normalize(content string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(content) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func fingerprint(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenize returns the distinct words of content, which is what the Bayes
+// model counts: a word either appears in a document or it does not.
+func tokenize(content string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, word := range strings.Fields(normalize(content)) {
+		if len(word) < 2 || len(word) > 30 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+		if len(tokens) == maxTokens {
+			break
+		}
+	}
+	return tokens
+}
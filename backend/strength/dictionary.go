@@ -0,0 +1,73 @@
+package strength
+
+import "strings"
+
+// commonPasswords and commonWords rank a few hundred of the passwords and
+// English words people use most, most common first. They only have to
+// catch the obvious: the breached password corpus, when one is loaded,
+// rejects known passwords outright.
+var (
+	commonPasswords = ranked(`
+		123456 password 12345678 qwerty 123456789 12345 1234 111111 1234567
+		dragon 123123 baseball abc123 football monkey letmein 696969 shadow
+		master 666666 qwertyuiop 123321 mustang 1234567890 michael 654321
+		superman 1qaz2wsx 7777777 121212 000000 qazwsx 123qwe killer trustno1
+		jordan jennifer zxcvbnm asdfgh hunter buster soccer harley batman
+		andrew tigger sunshine iloveyou 2000 charlie robert thomas hockey
+		ranger daniel starwars klaster 112233 george computer michelle
+		jessica pepper 1111 zxcvbn 555555 11111111 131313 freedom 777777 pass
+		maggie 159753 aaaaaa ginger princess joshua cheese amanda summer love
+		ashley nicole chelsea biteme matthew access yankees 987654321 dallas
+		austin thunder taylor matrix minecraft william corvette hello martin
+		heather secret merlin diamond 1234qwer gfhjkm hammer silver 222222
+		88888888 anthony justin test bailey q1w2e3r4t5 patrick internet
+		scooter orange 11111 golfer cookie richard samantha bigdog guitar
+		jackson whatever mickey chicken sparky snoopy maverick phoenix camaro
+		peanut morgan welcome falcon cowboy ferrari samsung andrea smokey
+		steelers joseph mercedes dakota arsenal eagles melissa boomer booboo
+		spider nascar monster tigers yellow xxxxxx 123123123 gateway marina
+		diablo bulldog qwer1234 compaq purple hardcore banana junior hannah
+		123654 porsche lakers iceman money cowboys 987654 london tennis 999999
+		ncc1701 coffee scooby 0000 miller boston q1w2e3r4 brandon yamaha
+		chester mother forever johnny edward 333333 oliver redsox player
+		nikita knight fender barney midnight please brandy chicago badboy
+		slayer rangers charles angel flower rabbit wizard bigdick jasper
+		rainbow admin qwerty123 passw0rd password1 password123 welcome1
+		letmein1 abc12345 changeme login root default guest`)
+
+	commonWords = ranked(`
+		the be to of and a in that have it for not on with he as you do at
+		this but his by from they we say her she or an will my one all would
+		there their what so up out if about who get which go me when make can
+		like time no just him know take people into year your good some could
+		them see other than then now look only come its over think also back
+		after use two how our work first well way even new want because any
+		these give day most us man woman child world life hand part place case
+		week company system program question government number night point
+		home water room mother area money story fact month lot right study
+		book eye job word business issue side kind head house service friend
+		father power hour game line end member law car city community name
+		president team minute idea kid body information school face others
+		level office door health person art war history party result change
+		morning reason research girl guy moment air teacher force education
+		blue red green black white orange yellow purple pink brown silver gold
+		spring summer autumn winter january february march april may june july
+		august september october november december monday tuesday wednesday
+		thursday friday saturday sunday dog cat horse tiger lion bear eagle
+		wolf fish bird apple banana cherry lemon coffee chocolate pizza cookie
+		football soccer baseball hockey tennis golf music guitar piano dance
+		happy sunny lucky super strong secure safe hello welcome letme secret
+		love angel baby honey sweet star moon sun sky fire ice rock stone
+		dragon magic king queen prince princess blog admin user login pass
+		word`)
+)
+
+func ranked(list string) dictionary {
+	words := dictionary{}
+	for _, word := range strings.Fields(list) {
+		if _, ok := words[word]; !ok {
+			words[word] = len(words) + 1
+		}
+	}
+	return words
+}
@@ -0,0 +1,353 @@
+// Package strength estimates how hard a password is to guess, in the
+// manner of zxcvbn: the password is covered with the patterns an attacker
+// tries first (common passwords and words, the user's own details,
+// keyboard rows, sequences, repeats and years) and whatever is left over is
+// counted as brute force. The estimate is the cheapest such cover.
+package strength
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Result is a password's estimated strength.
+type Result struct {
+	// Score runs from 0, guessed within a thousand tries, to 4, out of
+	// reach of an online attack by a wide margin.
+	Score int `json:"score"`
+	// EntropyBits is the base 2 logarithm of the number of guesses the
+	// password is estimated to take.
+	EntropyBits float64 `json:"entropyBits"`
+	// Feedback says what makes the password weak, if anything does.
+	Feedback []string `json:"feedback,omitempty"`
+}
+
+// scoreBits are the entropies at which the score goes up: zxcvbn's
+// thresholds of 10^3, 10^6, 10^8 and 10^10 guesses.
+var scoreBits = []float64{3 * math.Log2(10), 6 * math.Log2(10), 8 * math.Log2(10), 10 * math.Log2(10)}
+
+// minWordLength and maxWordLength bound the dictionary matches
+// considered.
+const (
+	minWordLength = 3
+	maxWordLength = 32
+)
+
+// maxLength is how many runes of a password are looked at. Anything longer
+// is out of reach of guessing whatever the rest holds, and the cap keeps
+// the work bounded for whatever a client sends.
+const maxLength = 128
+
+// match is a pattern covering runes i to j of a password, inclusive.
+type match struct {
+	i, j     int
+	bits     float64
+	feedback string
+}
+
+// Estimate estimates the strength of password. userInputs are things about
+// the user, such as their username and email address, that an attacker
+// going after them would try.
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxLength {
+		runes = runes[:maxLength]
+	}
+	if len(runes) == 0 {
+		return Result{Feedback: []string{"Enter a password"}}
+	}
+
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, lower, userDictionary(userInputs))...)
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, keyboardMatches(lower)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	endingAt := make([][]*match, len(runes))
+	for m := range matches {
+		endingAt[matches[m].j] = append(endingAt[matches[m].j], &matches[m])
+	}
+
+	// best[k] is the cheapest cover of the first k runes, and last[k] the
+	// match it ends with, if any.
+	bruteBits := math.Log2(float64(cardinality(runes)))
+	best := make([]float64, len(runes)+1)
+	last := make([]*match, len(runes)+1)
+	for k := 1; k <= len(runes); k++ {
+		best[k] = best[k-1] + bruteBits
+		for _, m := range endingAt[k-1] {
+			// Every pattern costs a bit more for having to try where the
+			// patterns start and end.
+			if bits := best[m.i] + m.bits + 1; bits < best[k] {
+				best[k], last[k] = bits, m
+			}
+		}
+	}
+
+	result := Result{EntropyBits: math.Round(best[len(runes)]*10) / 10}
+	for _, threshold := range scoreBits {
+		if best[len(runes)] >= threshold {
+			result.Score++
+		}
+	}
+
+	seen := map[string]bool{}
+	for k := len(runes); k > 0; {
+		m := last[k]
+		if m == nil {
+			k--
+			continue
+		}
+		if !seen[m.feedback] {
+			seen[m.feedback] = true
+			result.Feedback = append(result.Feedback, m.feedback)
+		}
+		k = m.i
+	}
+	if result.Score < 3 {
+		result.Feedback = append(result.Feedback, "Add another word or two; uncommon words are better")
+	}
+	return result
+}
+
+// cardinality is the size of the smallest alphabet password is written in.
+func cardinality(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	n := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			n += class.size
+		}
+	}
+	return n
+}
+
+// leet undoes the substitutions people make to dress words up.
+var leet = map[rune]rune{
+	'@': 'a', '4': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i',
+	'!': 'i', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+type dictionary map[string]int
+
+// userDictionary ranks the user's details, and the words in them, ahead of
+// everything else.
+func userDictionary(inputs []string) dictionary {
+	words := dictionary{}
+	add := func(word string) {
+		if len([]rune(word)) >= minWordLength {
+			if _, ok := words[word]; !ok {
+				words[word] = len(words) + 1
+			}
+		}
+	}
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		add(input)
+		for _, word := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			add(word)
+		}
+	}
+	return words
+}
+
+func dictionaryMatches(runes, lower []rune, user dictionary) []match {
+	var matches []match
+	for i := range lower {
+		for j := i + minWordLength - 1; j < len(lower) && j-i < maxWordLength; j++ {
+			word := string(lower[i : j+1])
+			plain, substitutions := unleet(lower[i : j+1])
+			capitals := capitalizationBits(runes[i : j+1])
+
+			best := match{i: i, j: j}
+			for _, candidate := range []struct {
+				word          string
+				substitutions int
+			}{{word, 0}, {plain, substitutions}} {
+				rank, feedback := lookup(candidate.word, user)
+				if rank == 0 {
+					continue
+				}
+				bits := capitals + float64(candidate.substitutions) + math.Log2(float64(rank))
+				if candidate.substitutions > 0 {
+					feedback = "Predictable substitutions like '@' instead of 'a' don't help very much"
+				}
+				if best.feedback == "" || bits < best.bits {
+					best.bits, best.feedback = bits, feedback
+				}
+			}
+			if best.feedback != "" {
+				matches = append(matches, best)
+			}
+		}
+	}
+	return matches
+}
+
+// lookup finds word in the user's details or the built-in dictionaries and
+// returns its rank, 0 if it is in none of them.
+func lookup(word string, user dictionary) (int, string) {
+	if rank, ok := user[word]; ok {
+		return rank, "Avoid using your name or email address"
+	}
+	if rank, ok := commonPasswords[word]; ok {
+		return rank, "This is a commonly used password"
+	}
+	if rank, ok := commonWords[word]; ok {
+		return rank, "Common words are easy to guess"
+	}
+	return 0, ""
+}
+
+func unleet(word []rune) (string, int) {
+	plain := make([]rune, len(word))
+	substitutions := 0
+	for i, r := range word {
+		if sub, ok := leet[r]; ok {
+			plain[i] = sub
+			substitutions++
+		} else {
+			plain[i] = r
+		}
+	}
+	return string(plain), substitutions
+}
+
+// capitalizationBits is what guessing where the capitals go costs:
+// nothing for all lower case, a bit for a capital first letter or all
+// capitals, and a bit per capital otherwise.
+func capitalizationBits(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(runes), upper == 1 && unicode.IsUpper(runes[0]):
+		return 1
+	}
+	return float64(upper)
+}
+
+// sequenceMatches finds runs like abcd and 9876.
+func sequenceMatches(lower []rune) []match {
+	var matches []match
+	for i := 0; i+2 < len(lower); {
+		delta := lower[i+1] - lower[i]
+		j := i + 1
+		if delta == 1 || delta == -1 {
+			for j+1 < len(lower) && lower[j+1]-lower[j] == delta {
+				j++
+			}
+		}
+		if j-i >= 2 {
+			start := 26.0
+			switch {
+			case strings.ContainsRune("az019", lower[i]):
+				start = 4
+			case unicode.IsDigit(lower[i]):
+				start = 10
+			}
+			bits := math.Log2(start) + math.Log2(float64(j-i+1))
+			if delta < 0 {
+				bits++
+			}
+			matches = append(matches, match{i: i, j: j, bits: bits, feedback: "Sequences like abc or 6543 are easy to guess"})
+			i = j
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+// repeatMatches finds runs of one character like aaaa.
+func repeatMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i < len(runes); {
+		j := i
+		for j+1 < len(runes) && runes[j+1] == runes[i] {
+			j++
+		}
+		if j-i >= 2 {
+			bits := math.Log2(float64(cardinality(runes[i:i+1]))) + math.Log2(float64(j-i+1))
+			matches = append(matches, match{i: i, j: j, bits: bits, feedback: "Repeated characters like aaa are easy to guess"})
+		}
+		i = j + 1
+	}
+	return matches
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// keyboardMatches finds four or more neighbouring keys along a row, in
+// either direction.
+func keyboardMatches(lower []rune) []match {
+	var matches []match
+	for _, row := range keyboardRows {
+		for _, line := range []string{row, reverse(row)} {
+			for i := range lower {
+				j := i
+				for j+1 < len(lower) && strings.Contains(line, string(lower[i:j+2])) {
+					j++
+				}
+				if j-i >= 3 {
+					bits := math.Log2(float64(2*len(keyboardRows)*len(row))) + math.Log2(float64(j-i+1))
+					matches = append(matches, match{i: i, j: j, bits: bits, feedback: "Straight rows of keys are easy to guess"})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// yearMatches finds years from 1900 to 2099.
+func yearMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i+3 < len(runes); i++ {
+		year := string(runes[i : i+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+			unicode.IsDigit(runes[i+2]) && unicode.IsDigit(runes[i+3]) {
+			matches = append(matches, match{i: i, j: i + 3, bits: math.Log2(200), feedback: "Recent years are easy to guess"})
+		}
+	}
+	return matches
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
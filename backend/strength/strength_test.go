@@ -0,0 +1,59 @@
+package strength
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateScores(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		score    int
+	}{
+		{"password", 0},
+		{"qwerty", 0},
+		{"P@ssw0rd", 0},
+		{"aaaaaaaa", 0},
+		{"abcdefgh", 0},
+		{"Password1!", 1},
+		{"alice2024!", 1},
+		{"Correct#Horse1", 4},
+		{"xK9#mQ2$vL7!pR4@", 4},
+		{"correct horse battery staple", 4},
+	} {
+		result := Estimate(tc.password, "alice", "alice@example.com")
+		assert.Equal(t, tc.score, result.Score, "%s: %+v", tc.password, result)
+	}
+}
+
+func TestEstimateFeedback(t *testing.T) {
+	assert.Contains(t, Estimate("password").Feedback, "This is a commonly used password")
+	assert.Contains(t, Estimate("P@ssw0rd").Feedback, "Predictable substitutions like '@' instead of 'a' don't help very much")
+	assert.Contains(t, Estimate("12345678").Feedback, "This is a commonly used password")
+	assert.Contains(t, Estimate("zyxwvuts").Feedback, "Sequences like abc or 6543 are easy to guess")
+	assert.Contains(t, Estimate("asdfghjk").Feedback, "Straight rows of keys are easy to guess")
+	assert.Contains(t, Estimate("zzzzzzzz").Feedback, "Repeated characters like aaa are easy to guess")
+	assert.Contains(t, Estimate("x1987x").Feedback, "Recent years are easy to guess")
+	assert.Contains(t, Estimate("Mallory!42", "mallory").Feedback, "Avoid using your name or email address")
+	assert.Empty(t, Estimate("xK9#mQ2$vL7!pR4@").Feedback)
+}
+
+func TestEstimateUserInputs(t *testing.T) {
+	// The same password is weaker for the user it names.
+	assert.Less(t, Estimate("Zelda#Ludwig", "zelda_ludwig", "zelda@example.com").EntropyBits,
+		Estimate("Zelda#Ludwig").EntropyBits)
+}
+
+func TestEstimateEmpty(t *testing.T) {
+	result := Estimate("")
+	assert.Zero(t, result.Score)
+	assert.Zero(t, result.EntropyBits)
+}
+
+func TestEstimateLongPassword(t *testing.T) {
+	// Only the start of an overlong password is looked at.
+	long := strings.Repeat("correct horse battery staple ", 40000)
+	assert.Equal(t, Estimate(long[:maxLength]), Estimate(long))
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DiffContextLines = 3
+
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// UnifiedDiff returns a unified line diff turning a into b, in the format
+// produced by diff -u. It returns an empty string when a and b are equal.
+func UnifiedDiff(a, b, fromName, toName string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	for _, h := range buildHunks(lines, DiffContextLines) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.fromLine, h.fromCount), hunkRange(h.toLine, h.toCount))
+		for _, l := range h.lines {
+			out.WriteByte(byte(l.op))
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a shortest edit script with the linear space
+// variant of Myers' algorithm: rather than keeping every step of the
+// search, it finds the middle of an optimal path and recurses on either
+// side, so memory stays proportional to the input however many edits
+// there are.
+func diffLines(a, b []string) []diffLine {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+	size := len(a) + len(b) + 3
+	d := &differ{
+		a:       a,
+		b:       b,
+		forward: make([]int, size),
+		reverse: make([]int, size),
+		lines:   make([]diffLine, 0, len(a)+len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+	return groupChanges(d.lines)
+}
+
+type differ struct {
+	a, b []string
+	// forward and reverse hold, for each diagonal, how far the furthest
+	// reaching paths from either end have got.
+	forward, reverse []int
+	lines            []diffLine
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.lines = append(d.lines, diffLine{diffEqual, d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for _, line := range d.b[bLo:bHi] {
+			d.lines = append(d.lines, diffLine{diffInsert, line})
+		}
+	case bLo == bHi:
+		for _, line := range d.a[aLo:aHi] {
+			d.lines = append(d.lines, diffLine{diffDelete, line})
+		}
+	default:
+		// With the common ends gone, both sides are non-empty and differ
+		// by at least two edits, so both halves are smaller problems.
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for _, line := range d.a[x:u] {
+			d.lines = append(d.lines, diffLine{diffEqual, line})
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for _, line := range d.a[aHi : aHi+suffix] {
+		d.lines = append(d.lines, diffLine{diffEqual, line})
+	}
+}
+
+// middleSnake searches for a shortest path from both corners of the edit
+// graph of a[aLo:aHi] and b[bLo:bHi] at once and returns the run of equal
+// lines, from (x, y) to (u, v), where the two searches meet.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonal k, the lines of a used less those of b, runs from -m to n.
+	// Diagonals no path has reached hold -1.
+	offset := m + 1
+	forward, reverse := d.forward[:n+m+3], d.reverse[:n+m+3]
+	for i := range forward {
+		forward[i], reverse[i] = -1, -1
+	}
+	forward[offset+1], reverse[offset+1] = 0, 0
+
+	// furthest extends the paths on diagonal k by one edit and then along
+	// any equal lines. equal compares lines counted along the path.
+	furthest := func(fr []int, k int, equal func(x, y int) bool) (int, int) {
+		x := -1
+		if down := fr[offset+k+1]; down >= 0 && down-k <= m {
+			x = down
+		}
+		if right := fr[offset+k-1]; right >= 0 && right+1 <= n && right+1 > x {
+			x = right + 1
+		}
+		if x < 0 {
+			return -1, -1
+		}
+		start := x
+		for x < n && x-k < m && equal(x, x-k) {
+			x++
+		}
+		return start, x
+	}
+	forwardEqual := func(x, y int) bool { return a[x] == b[y] }
+	reverseEqual := func(x, y int) bool { return a[n-1-x] == b[m-1-y] }
+
+	for steps := 0; ; steps++ {
+		lo, hi := -steps, steps
+		for lo < -m {
+			lo += 2
+		}
+		for hi > n {
+			hi -= 2
+		}
+
+		for k := lo; k <= hi; k += 2 {
+			start, end := furthest(forward, k, forwardEqual)
+			forward[offset+k] = end
+			if end < 0 || !odd {
+				continue
+			}
+			// The reverse search, one step behind, runs on diagonal
+			// delta-k in its own coordinates.
+			if r := delta - k; r >= -m && r <= n && reverse[offset+r] >= 0 && end+reverse[offset+r] >= n {
+				return aLo + start, bLo + start - k, aLo + end, bLo + end - k
+			}
+		}
+
+		for k := lo; k <= hi; k += 2 {
+			start, end := furthest(reverse, k, reverseEqual)
+			reverse[offset+k] = end
+			if end < 0 || odd {
+				continue
+			}
+			if f := delta - k; f >= -m && f <= n && forward[offset+f] >= 0 && end+forward[offset+f] >= n {
+				return aLo + n - end, bLo + m - (end - k), aLo + n - start, bLo + m - (start - k)
+			}
+		}
+	}
+}
+
+// groupChanges puts the deletions in each run of changes ahead of the
+// insertions, as diff -u does.
+func groupChanges(lines []diffLine) []diffLine {
+	out := make([]diffLine, 0, len(lines))
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(lines) && lines[j].op != diffEqual {
+			j++
+		}
+		for _, op := range []diffOp{diffDelete, diffInsert} {
+			for _, l := range lines[i:j] {
+				if l.op == op {
+					out = append(out, l)
+				}
+			}
+		}
+		i = j
+	}
+	return out
+}
+
+type hunk struct {
+	fromLine, fromCount int
+	toLine, toCount     int
+	lines               []diffLine
+}
+
+// buildHunks groups changes with up to context unchanged lines around
+// them, merging groups whose context would overlap.
+func buildHunks(lines []diffLine, context int) []hunk {
+	// Line numbers in a and b at which each diff line sits.
+	fromAt := make([]int, len(lines))
+	toAt := make([]int, len(lines))
+	var changes []int
+	fromLine, toLine := 1, 1
+	for i, l := range lines {
+		fromAt[i], toAt[i] = fromLine, toLine
+		if l.op != diffInsert {
+			fromLine++
+		}
+		if l.op != diffDelete {
+			toLine++
+		}
+		if l.op != diffEqual {
+			changes = append(changes, i)
+		}
+	}
+
+	var hunks []hunk
+	for i := 0; i < len(changes); {
+		first, last := changes[i], changes[i]
+		for i++; i < len(changes) && changes[i]-last <= 2*context+1; i++ {
+			last = changes[i]
+		}
+
+		start, end := first-context, last+context+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		h := hunk{fromLine: fromAt[start], toLine: toAt[start], lines: lines[start:end]}
+		for _, l := range h.lines {
+			if l.op != diffInsert {
+				h.fromCount++
+			}
+			if l.op != diffDelete {
+				h.toCount++
+			}
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		// An empty range points at the line before the change.
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
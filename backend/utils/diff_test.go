@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\ntwo\nthree\nfour\n5\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	expected := strings.Join([]string{
+		"--- rev 1",
+		"+++ rev 2",
+		"@@ -2,9 +2,10 @@",
+		" two",
+		" three",
+		" four",
+		"-five",
+		"+5",
+		" six",
+		" seven",
+		" eight",
+		" nine",
+		" ten",
+		"+eleven",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, UnifiedDiff(a, b, "rev 1", "rev 2"))
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		line := string(rune('a' + i))
+		a = append(a, line)
+		if i == 1 || i == 17 {
+			line = strings.ToUpper(line)
+		}
+		b = append(b, line)
+	}
+
+	diff := UnifiedDiff(strings.Join(a, "\n"), strings.Join(b, "\n"), "a", "b")
+	assert.Contains(t, diff, "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n")
+	assert.Contains(t, diff, "@@ -15,6 +15,6 @@\n o\n p\n q\n-r\n+R\n s\n t\n")
+}
+
+func TestUnifiedDiffEdgeCases(t *testing.T) {
+	assert.Equal(t, "", UnifiedDiff("same\n", "same\n", "a", "b"))
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+new\n+text\n", UnifiedDiff("", "new\ntext", "a", "b"))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-gone\n", UnifiedDiff("gone", "", "a", "b"))
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+
+		// The longest common subsequence, by the textbook table.
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		from, to := []string{}, []string{}
+		edits := 0
+		for _, l := range diffLines(a, b) {
+			if l.op != diffInsert {
+				from = append(from, l.text)
+			}
+			if l.op != diffDelete {
+				to = append(to, l.text)
+			}
+			if l.op != diffEqual {
+				edits++
+			}
+		}
+		assert.Equal(t, a, from)
+		assert.Equal(t, b, to)
+		assert.Equal(t, len(a)+len(b)-2*lcs[0][0], edits, "%q -> %q", a, b)
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	html, err := RenderMarkdown("## Getting Started\n\nSee the note[^1].\n\n```go\nfmt.Println(\"hi\")\n```\n\n[^1]: A footnote.\n")
+	require.NoError(t, err)
+
+	assert.Contains(t, html, `<h2 id="getting-started">Getting Started</h2>`)
+	assert.Contains(t, html, `<code class="language-go">`)
+	assert.Contains(t, html, `<a href="#fn:1" class="footnote-ref" role="doc-noteref"`)
+	assert.Contains(t, html, `<li id="fn:1">`)
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	inputs := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"<a href=\"#\" onclick=\"alert(1)\">x</a>",
+		"```\" onmouseover=\"alert(1)\n```",
+	}
+
+	for _, input := range inputs {
+		html, err := RenderMarkdown(input)
+		require.NoError(t, err)
+		assert.NotContains(t, html, "<script", input)
+		assert.NotContains(t, html, "onerror", input)
+		assert.NotContains(t, html, "onclick", input)
+		assert.NotContains(t, html, "onmouseover=", input)
+		assert.NotContains(t, html, "javascript:", input)
+	}
+}
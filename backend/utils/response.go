@@ -21,6 +21,11 @@ type PaginatedResponse struct {
 	TotalPages int         `json:"total_pages"`
 }
 
+type CursorResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
 func SuccessResponse(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, Response{
 		Status: "success",
@@ -35,6 +40,16 @@ func ErrorResponse(c *gin.Context, code int, message string) {
 	})
 }
 
+// ErrorResponseWithData is ErrorResponse with details the client can use to
+// put things right.
+func ErrorResponseWithData(c *gin.Context, code int, message string, data interface{}) {
+	c.JSON(code, Response{
+		Status: "error",
+		Error:  message,
+		Data:   data,
+	})
+}
+
 func PaginatedSuccessResponse(c *gin.Context, items interface{}, total int64, page, pageSize int) {
 	totalPages := (int(total) + pageSize - 1) / pageSize
 
@@ -49,3 +64,13 @@ func PaginatedSuccessResponse(c *gin.Context, items interface{}, total int64, pa
 		},
 	})
 }
+
+func CursorSuccessResponse(c *gin.Context, items interface{}, nextCursor string) {
+	c.JSON(http.StatusOK, Response{
+		Status: "success",
+		Data: CursorResponse{
+			Items:      items,
+			NextCursor: nextCursor,
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		title    string
+		expected string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Go 1.22: What's new?  ", "go-1-22-whats-new"},
+		{"Crème brûlée für Anfänger", "creme-brulee-fur-anfanger"},
+		{"Straße & Smørrebrød", "strasse-smorrebrod"},
+		{"Привет, мир", "privet-mir"},
+		{"Ελληνικά", "ellinika"},
+		{"ﬁne ligatures", "fine-ligatures"},
+		{"日本語", "post"},
+		{"---", "post"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Slugify(tc.title), tc.title)
+	}
+}
+
+func TestSlugifyTruncatesAtWordBoundary(t *testing.T) {
+	slug := Slugify(strings.Repeat("lorem ipsum ", 20))
+	assert.LessOrEqual(t, len(slug), MaxSlugLength)
+	assert.False(t, strings.HasSuffix(slug, "-"))
+	assert.True(t, strings.HasSuffix(slug, "lorem") || strings.HasSuffix(slug, "ipsum"))
+}
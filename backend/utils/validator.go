@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"unicode"
@@ -20,7 +20,6 @@ func init() {
 
 func validatePassword(fl validator.FieldLevel) bool {
 	password := fl.Field().String()
-	fmt.Println("Password Validation Triggered:", password) // debug log
 	var (
 		hasMinLen  = false
 		hasUpper   = false
@@ -69,6 +68,14 @@ func FormatValidationErrors(err error) string {
 					"password must be at least 8 characters and contain "+
 						"at least one uppercase letter, one lowercase letter, "+
 						"and one number")
+			case "max":
+				if e.Kind() == reflect.String {
+					errorMessages = append(errorMessages,
+						strings.ToLower(e.Field())+" must be at most "+e.Param()+" characters")
+				} else {
+					errorMessages = append(errorMessages,
+						strings.ToLower(e.Field())+" is invalid")
+				}
 			case "username":
 				errorMessages = append(errorMessages,
 					"username must be 3-20 characters long and can only contain "+
@@ -82,3 +89,15 @@ func FormatValidationErrors(err error) string {
 	}
 	return "validation error"
 }
+
+// FieldFailed reports whether err, from Validate, is about the named field.
+func FieldFailed(err error, field string) bool {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			if e.Field() == field {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -22,7 +22,7 @@ export default function Login() {
 
     try {
       const response = await api.post('/auth/login', {
-        email,
+        identifier: email,
         password
       })
       const { token } = response.data
@@ -30,7 +30,7 @@ export default function Settings() {
       })
       const { token } = response.data
       await logout()
-      await api.post('/auth/login', { email, password: newPassword })
+      await api.post('/auth/login', { identifier: email, password: newPassword })
       toast({ variant: 'success', title: 'Success', description: 'Settings updated.' })
     } catch (error) {
       toast({ variant: 'destructive', title: 'Error', description: 'Failed to update settings.' })